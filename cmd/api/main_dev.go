@@ -10,26 +10,24 @@ import (
         "time"
 
         "stories-service/internal/cache"
+        "stories-service/internal/config"
         "stories-service/internal/db"
         "stories-service/internal/handlers"
         "stories-service/internal/middleware"
+        "stories-service/internal/notifications"
+        "stories-service/internal/pagination"
+        "stories-service/internal/presence"
         "stories-service/internal/storage"
+        "stories-service/internal/visibility"
         "stories-service/internal/websocket"
         "stories-service/pkg/logger"
 
         "github.com/gin-gonic/gin"
-        ws "github.com/gorilla/websocket"
         "github.com/joho/godotenv"
         "github.com/prometheus/client_golang/prometheus/promhttp"
         "go.uber.org/zap"
 )
 
-var upgrader = ws.Upgrader{
-        CheckOrigin: func(r *http.Request) bool {
-                return true
-        },
-}
-
 func main() {
         godotenv.Load()
 
@@ -50,9 +48,11 @@ func main() {
         }
         defer database.Close()
 
-        if err := database.InitSchema(); err != nil {
-                logger.Fatal("failed to initialize schema", zap.Error(err))
+        applied, err := database.Migrate(context.Background())
+        if err != nil {
+                logger.Fatal("failed to run migrations", zap.Error(err))
         }
+        logger.Info("database migrated", zap.Int("applied", applied))
 
         var redisCache *cache.Cache
         redisAddr := os.Getenv("REDIS_ADDR")
@@ -91,7 +91,19 @@ func main() {
                 stor = nil
         }
 
-        hub := websocket.NewHub()
+        var broker websocket.Broker = websocket.NewLocalBroker()
+        if redisCache != nil {
+                broker = websocket.NewRedisBroker(redisCache.Client())
+                logger.Info("websocket events fanned out through redis pub/sub")
+        }
+        defer broker.Close()
+
+        inbox := notifications.NewStore(database)
+        var watchers websocket.Presence
+        if redisCache != nil {
+                watchers = presence.NewTracker(database, redisCache)
+        }
+        hub := websocket.NewHub(broker, inbox, visibility.NewEvaluator(database), watchers, logger)
         go hub.Run()
 
         jwtSecret := os.Getenv("JWT_SECRET")
@@ -100,14 +112,21 @@ func main() {
                 logger.Warn("Using default JWT secret - this is insecure!")
         }
 
+        revocationFailOpen := os.Getenv("AUTH_REVOCATION_FAIL_OPEN") == "true"
+        if redisCache == nil {
+                logger.Warn("running without redis, access tokens cannot be revoked before they expire")
+        } else if revocationFailOpen {
+                logger.Warn("AUTH_REVOCATION_FAIL_OPEN set, tokens are accepted while redis is unreachable")
+        }
+
         router := gin.New()
         router.Use(gin.Recovery())
         router.Use(middleware.MetricsMiddleware())
 
         router.Use(func(c *gin.Context) {
                 c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-                c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-                c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+                c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+                c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID")
                 if c.Request.Method == "OPTIONS" {
                         c.AbortWithStatus(204)
                         return
@@ -117,50 +136,101 @@ func main() {
 
         router.GET("/", handlers.RootHandler)
 
-        authHandler := handlers.NewAuthHandler(database, jwtSecret, logger)
+        tokenLifetime, err := config.LoadTokenLifetime()
+        if err != nil {
+                logger.Fatal("invalid token lifetime configuration", zap.Error(err))
+        }
+
+        authHandler := handlers.NewAuthHandler(database, redisCache, jwtSecret, tokenLifetime, logger)
         router.POST("/signup", authHandler.Signup)
         router.POST("/login", authHandler.Login)
+        router.POST("/token/refresh", authHandler.Refresh)
 
         uploadHandler := handlers.NewUploadHandler(stor, logger)
-        storiesHandler := handlers.NewStoriesHandler(database, stor, redisCache, hub, logger)
-        socialHandler := handlers.NewSocialHandler(database, logger)
+        cursorSecret := os.Getenv("CURSOR_SECRET")
+        if cursorSecret == "" {
+                cursorSecret = jwtSecret
+        }
+        cursors := pagination.NewCodec(cursorSecret)
+
+        storyLifetime, err := config.LoadStoryLifetime()
+        if err != nil {
+                logger.Fatal("invalid story lifetime configuration", zap.Error(err))
+        }
+
+        feedFanout, err := config.LoadFeedFanout()
+        if err != nil {
+                logger.Fatal("invalid feed fan-out configuration", zap.Error(err))
+        }
+
+        reactionCatalogue, err := config.LoadReactions()
+        if err != nil {
+                logger.Fatal("invalid reaction catalogue configuration", zap.Error(err))
+        }
+
+        storiesHandler := handlers.NewStoriesHandler(database, stor, redisCache, hub, cursors, storyLifetime, feedFanout, reactionCatalogue, logger)
+        socialHandler := handlers.NewSocialHandler(database, redisCache, hub, cursors, logger)
+        highlightsHandler := handlers.NewHighlightsHandler(database, logger)
+        repliesHandler := handlers.NewRepliesHandler(database, redisCache, hub, cursors, logger)
+        notificationsHandler := handlers.NewNotificationsHandler(database, inbox, logger)
+        streamHandler := handlers.NewStreamHandler(hub, inbox, logger)
         healthHandler := handlers.NewHealthHandler(database, redisCache, stor)
 
+        router.GET("/reactions/catalogue", storiesHandler.GetReactionCatalogue)
         router.GET("/healthz", healthHandler.Health)
         router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
         authRoutes := router.Group("/")
-        authRoutes.Use(middleware.AuthMiddleware(jwtSecret))
+        authRoutes.Use(middleware.AuthMiddleware(jwtSecret, redisCache, revocationFailOpen, logger))
         {
+                authRoutes.POST("/logout", authHandler.Logout)
                 authRoutes.POST("/upload/presigned", uploadHandler.GetPresignedURL)
                 authRoutes.POST("/stories", storiesHandler.CreateStory)
                 authRoutes.GET("/stories/:id", storiesHandler.GetStory)
+                authRoutes.PATCH("/stories/:id", storiesHandler.UpdateStory)
+                authRoutes.DELETE("/stories/:id", storiesHandler.DeleteStory)
                 authRoutes.GET("/feed", storiesHandler.GetFeed)
                 authRoutes.POST("/stories/:id/view", storiesHandler.ViewStory)
+                authRoutes.GET("/stories/:id/viewers", storiesHandler.GetViewers)
+                authRoutes.GET("/stories/:id/viewers/count", storiesHandler.GetViewerCount)
+                authRoutes.GET("/stories/:id/insights", storiesHandler.GetInsights)
+                authRoutes.GET("/stories/:id/presence", storiesHandler.GetPresence)
                 authRoutes.POST("/stories/:id/reactions", storiesHandler.AddReaction)
+                authRoutes.DELETE("/stories/:id/reactions", storiesHandler.RemoveReaction)
+                authRoutes.POST("/stories/:id/replies", repliesHandler.CreateReply)
+                authRoutes.GET("/stories/:id/replies", repliesHandler.ListStoryReplies)
+                authRoutes.GET("/reply-threads/:id/messages", repliesHandler.ListThreadMessages)
+                authRoutes.POST("/reply-threads/:id/messages", repliesHandler.SendThreadMessage)
                 authRoutes.GET("/me/stats", storiesHandler.GetStats)
+                authRoutes.GET("/me/notifications", notificationsHandler.ListNotifications)
+                authRoutes.POST("/me/notifications/read", notificationsHandler.MarkRead)
+                authRoutes.PUT("/me/privacy", socialHandler.SetPrivacy)
+                authRoutes.GET("/me/follow-requests", socialHandler.ListFollowRequests)
+                authRoutes.POST("/me/follow-requests/:user_id/approve", socialHandler.ApproveFollowRequest)
+                authRoutes.DELETE("/me/follow-requests/:user_id", socialHandler.RejectFollowRequest)
                 authRoutes.POST("/follow/:user_id", socialHandler.Follow)
                 authRoutes.DELETE("/follow/:user_id", socialHandler.Unfollow)
+                authRoutes.POST("/block/:user_id", socialHandler.Block)
+                authRoutes.DELETE("/block/:user_id", socialHandler.Unblock)
+                authRoutes.POST("/mute/:user_id", socialHandler.Mute)
+                authRoutes.DELETE("/mute/:user_id", socialHandler.Unmute)
+                authRoutes.POST("/highlights", highlightsHandler.CreateHighlight)
+                authRoutes.GET("/highlights/:id", highlightsHandler.GetHighlight)
+                authRoutes.PATCH("/highlights/:id", highlightsHandler.UpdateHighlight)
+                authRoutes.DELETE("/highlights/:id", highlightsHandler.DeleteHighlight)
+                authRoutes.POST("/highlights/:id/stories", highlightsHandler.AddStory)
+                authRoutes.DELETE("/highlights/:id/stories/:story_id", highlightsHandler.RemoveStory)
+                authRoutes.GET("/users/:id", socialHandler.GetProfile)
+                authRoutes.GET("/users/:id/followers", socialHandler.ListFollowers)
+                authRoutes.GET("/users/:id/following", socialHandler.ListFollowing)
+                authRoutes.GET("/users/:id/highlights", highlightsHandler.ListUserHighlights)
+        }
 
-                authRoutes.GET("/ws", func(c *gin.Context) {
-                        userID, ok := middleware.GetUserID(c)
-                        if !ok {
-                                c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-                                return
-                        }
-
-                        conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-                        if err != nil {
-                                logger.Error("websocket upgrade failed", zap.Error(err))
-                                return
-                        }
-
-                        client := websocket.NewClient(userID, hub, conn)
-                        hub.RegisterClient(client)
-
-                        go client.WritePump()
-                        go client.ReadPump()
-                })
+        streamRoutes := router.Group("/")
+        streamRoutes.Use(middleware.StreamAuthMiddleware(jwtSecret, redisCache, revocationFailOpen, logger))
+        {
+                streamRoutes.GET("/ws", streamHandler.WebSocket)
+                streamRoutes.GET("/events", streamHandler.Events)
         }
 
         port := os.Getenv("PORT")
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"stories-service/internal/analytics"
+	"stories-service/internal/db"
+
+	"github.com/joho/godotenv"
+)
+
+// Rollups are rebuilt a day at a time so each transaction stays short and
+// the worker's own refreshes can interleave.
+const chunk = 24 * time.Hour
+
+func main() {
+	godotenv.Load()
+
+	fromFlag := flag.String("from", "", "start of the range to rebuild, RFC 3339 (default: first story)")
+	toFlag := flag.String("to", "", "end of the range to rebuild, RFC 3339, rounded up to the hour (default: now)")
+	flag.Parse()
+
+	database, err := db.NewDB(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+
+	if _, err := database.Migrate(ctx); err != nil {
+		log.Fatalf("failed to run migrations: %v", err)
+	}
+
+	to := time.Now().Add(time.Hour)
+	if *toFlag != "" {
+		to, err = time.Parse(time.RFC3339, *toFlag)
+		if err != nil {
+			log.Fatalf("invalid -to: %v", err)
+		}
+	}
+
+	var from time.Time
+	if *fromFlag != "" {
+		from, err = time.Parse(time.RFC3339, *fromFlag)
+		if err != nil {
+			log.Fatalf("invalid -from: %v", err)
+		}
+	} else {
+		var first *time.Time
+		if err := database.QueryRowContext(ctx, "SELECT MIN(created_at) FROM stories").Scan(&first); err != nil {
+			log.Fatalf("failed to find first story: %v", err)
+		}
+		if first == nil {
+			fmt.Println("No stories to backfill")
+			return
+		}
+		from = *first
+	}
+
+	// Buckets are whole hours and RefreshRollups rounds both ends down, so
+	// round -to up to keep a final partial hour in range.
+	if hour := to.Truncate(time.Hour); hour.Before(to) {
+		to = hour.Add(time.Hour)
+	}
+
+	if !from.Before(to) {
+		log.Fatalf("-from must be before -to")
+	}
+
+	for start := from; start.Before(to); start = start.Add(chunk) {
+		end := start.Add(chunk)
+		if end.After(to) {
+			end = to
+		}
+		if err := analytics.RefreshRollups(ctx, database, start, end); err != nil {
+			log.Fatalf("backfill failed at %s: %v", start.Format(time.RFC3339), err)
+		}
+		fmt.Printf("Rebuilt rollups %s - %s\n", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+}
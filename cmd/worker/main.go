@@ -7,6 +7,8 @@ import (
 	"os/signal"
 	"syscall"
 
+	"stories-service/internal/cache"
+	"stories-service/internal/config"
 	"stories-service/internal/db"
 	"stories-service/internal/worker"
 	"stories-service/pkg/logger"
@@ -30,7 +32,40 @@ func main() {
 	}
 	defer database.Close()
 
-	w := worker.NewWorker(database, logger)
+	applied, err := database.Migrate(context.Background())
+	if err != nil {
+		logger.Fatal("failed to run migrations", zap.Error(err))
+	}
+	logger.Info("database migrated", zap.Int("applied", applied))
+
+	var redisCache *cache.Cache
+	if os.Getenv("REDIS_ADDR") != "" {
+		redisCache, err = cache.NewCache(
+			os.Getenv("REDIS_ADDR"),
+			os.Getenv("REDIS_PASSWORD"),
+			0,
+		)
+		if err != nil {
+			logger.Warn("failed to connect to redis, timelines will not be trimmed", zap.Error(err))
+			redisCache = nil
+		} else {
+			defer redisCache.Close()
+		}
+	} else {
+		logger.Warn("REDIS_ADDR not set, timelines will not be trimmed")
+	}
+
+	storyLifetime, err := config.LoadStoryLifetime()
+	if err != nil {
+		logger.Fatal("invalid story lifetime configuration", zap.Error(err))
+	}
+
+	feedFanout, err := config.LoadFeedFanout()
+	if err != nil {
+		logger.Fatal("invalid feed fan-out configuration", zap.Error(err))
+	}
+
+	w := worker.NewWorker(database, redisCache, storyLifetime, feedFanout, logger)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,198 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"stories-service/internal/db"
+
+	"github.com/lib/pq"
+)
+
+// rollupLockKey is the pg_advisory_xact_lock key that serialises refreshes,
+// so the worker and a running backfill never rebuild the same buckets at once.
+const rollupLockKey = 727274912
+
+// Each statement rebuilds the hourly buckets listed in $1 from the raw
+// stories, story_views and reactions rows.
+var rollupStatements = []string{
+	`DELETE FROM story_stats_hourly WHERE bucket = ANY($1::timestamptz[])`,
+	`DELETE FROM author_stats_hourly WHERE bucket = ANY($1::timestamptz[])`,
+	`DELETE FROM author_viewers_hourly WHERE bucket = ANY($1::timestamptz[])`,
+	`DELETE FROM reaction_stats_hourly WHERE bucket = ANY($1::timestamptz[])`,
+
+	`INSERT INTO story_stats_hourly (story_id, author_id, bucket, views, reactions)
+	SELECT story_id, author_id, bucket, SUM(views), SUM(reactions)
+	FROM (
+		SELECT sv.story_id, s.author_id, b.bucket, 1 AS views, 0 AS reactions
+		FROM unnest($1::timestamptz[]) AS b(bucket)
+		JOIN story_views sv ON sv.viewed_at >= b.bucket AND sv.viewed_at < b.bucket + INTERVAL '1 hour'
+		JOIN stories s ON s.id = sv.story_id
+		UNION ALL
+		SELECT r.story_id, s.author_id, b.bucket, 0, 1
+		FROM unnest($1::timestamptz[]) AS b(bucket)
+		JOIN reactions r ON r.created_at >= b.bucket AND r.created_at < b.bucket + INTERVAL '1 hour'
+		JOIN stories s ON s.id = r.story_id
+	) events
+	GROUP BY story_id, author_id, bucket`,
+
+	`INSERT INTO author_stats_hourly (author_id, bucket, posted, views, reactions)
+	SELECT author_id, bucket, SUM(posted), SUM(views), SUM(reactions)
+	FROM (
+		SELECT s.author_id, b.bucket, 1 AS posted, 0 AS views, 0 AS reactions
+		FROM unnest($1::timestamptz[]) AS b(bucket)
+		JOIN stories s ON s.created_at >= b.bucket AND s.created_at < b.bucket + INTERVAL '1 hour'
+		UNION ALL
+		SELECT author_id, bucket, 0, views, reactions
+		FROM story_stats_hourly
+		WHERE bucket = ANY($1::timestamptz[])
+	) events
+	GROUP BY author_id, bucket`,
+
+	`INSERT INTO author_viewers_hourly (author_id, bucket, viewer_id)
+	SELECT DISTINCT s.author_id, b.bucket, sv.viewer_id
+	FROM unnest($1::timestamptz[]) AS b(bucket)
+	JOIN story_views sv ON sv.viewed_at >= b.bucket AND sv.viewed_at < b.bucket + INTERVAL '1 hour'
+	JOIN stories s ON s.id = sv.story_id`,
+
+	`INSERT INTO reaction_stats_hourly (story_id, author_id, bucket, emoji, reactions)
+	SELECT r.story_id, s.author_id, b.bucket, r.emoji, COUNT(*)
+	FROM unnest($1::timestamptz[]) AS b(bucket)
+	JOIN reactions r ON r.created_at >= b.bucket AND r.created_at < b.bucket + INTERVAL '1 hour'
+	JOIN stories s ON s.id = r.story_id
+	GROUP BY r.story_id, s.author_id, b.bucket, r.emoji`,
+}
+
+// RefreshRollups recomputes every hourly rollup bucket between from and to,
+// both rounded down to the hour, in one transaction.
+func RefreshRollups(ctx context.Context, database *db.DB, from, to time.Time) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", rollupLockKey); err != nil {
+		return fmt.Errorf("failed to acquire rollup lock: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT generate_series(
+			date_trunc('hour', $1::timestamptz),
+			date_trunc('hour', $2::timestamptz) - INTERVAL '1 hour',
+			INTERVAL '1 hour'
+		)
+	`, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to list rollup buckets: %w", err)
+	}
+	buckets, err := scanBuckets(rows)
+	if err != nil {
+		return fmt.Errorf("failed to list rollup buckets: %w", err)
+	}
+
+	if err := rebuildBuckets(ctx, tx, buckets); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateRollups rebuilds only the hourly buckets that changed since the last
+// update: hours with stories, views or reactions written after the
+// high-water mark in rollup_state, and hours that lost or moved rows, which
+// triggers record in rollup_dirty_hours. Rows are folded in once they are
+// settle old, so a transaction that commits a row stamped a moment earlier
+// is not skipped. It returns the number of buckets rebuilt.
+func UpdateRollups(ctx context.Context, database *db.DB, settle time.Duration) (int, error) {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", rollupLockKey); err != nil {
+		return 0, fmt.Errorf("failed to acquire rollup lock: %w", err)
+	}
+
+	var from, upTo time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT processed_to, NOW() - make_interval(secs => $1)
+		FROM rollup_state
+		WHERE name = 'hourly'
+		FOR UPDATE
+	`, settle.Seconds()).Scan(&from, &upTo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rollup high-water mark: %w", err)
+	}
+	if !upTo.After(from) {
+		return 0, nil
+	}
+
+	// Dirty hours are claimed by deleting them. A change still in flight
+	// holds the hour's row, so it either commits before this and is seen by
+	// the rebuild, or marks the hour dirty again for the next update.
+	rows, err := tx.QueryContext(ctx, `
+		WITH dirty AS (
+			DELETE FROM rollup_dirty_hours RETURNING bucket
+		)
+		SELECT bucket FROM dirty
+		UNION
+		SELECT date_trunc('hour', created_at) FROM stories WHERE created_at > $1 AND created_at <= $2
+		UNION
+		SELECT date_trunc('hour', viewed_at) FROM story_views WHERE viewed_at > $1 AND viewed_at <= $2
+		UNION
+		SELECT date_trunc('hour', created_at) FROM reactions WHERE created_at > $1 AND created_at <= $2
+	`, from, upTo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find changed rollup buckets: %w", err)
+	}
+	buckets, err := scanBuckets(rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find changed rollup buckets: %w", err)
+	}
+
+	if err := rebuildBuckets(ctx, tx, buckets); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE rollup_state SET processed_to = $1 WHERE name = 'hourly'
+	`, upTo); err != nil {
+		return 0, fmt.Errorf("failed to advance rollup high-water mark: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(buckets), nil
+}
+
+// scanBuckets reads a column of bucket timestamps as the strings
+// rebuildBuckets passes back to Postgres.
+func scanBuckets(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var buckets []string
+	for rows.Next() {
+		var bucket time.Time
+		if err := rows.Scan(&bucket); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket.Format(time.RFC3339Nano))
+	}
+	return buckets, rows.Err()
+}
+
+func rebuildBuckets(ctx context.Context, tx *sql.Tx, buckets []string) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+	for _, statement := range rollupStatements {
+		if _, err := tx.ExecContext(ctx, statement, pq.Array(buckets)); err != nil {
+			return fmt.Errorf("failed to refresh rollups: %w", err)
+		}
+	}
+	return nil
+}
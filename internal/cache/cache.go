@@ -4,6 +4,8 @@ import (
         "context"
         "encoding/json"
         "fmt"
+        "strconv"
+        "strings"
         "time"
 
         "github.com/google/uuid"
@@ -72,6 +74,475 @@ func (c *Cache) SetFollowees(ctx context.Context, userID uuid.UUID, followees []
         return c.Set(ctx, key, followees, 5*time.Minute)
 }
 
+func (c *Cache) InvalidateFollowees(ctx context.Context, userIDs ...uuid.UUID) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        keys := make([]string, 0, len(userIDs))
+        for _, userID := range userIDs {
+                keys = append(keys, fmt.Sprintf("followees:%s", userID.String()))
+        }
+        return c.client.Del(ctx, keys...).Err()
+}
+
+// Feed pages are cached under a per-user version so every page for a user
+// can be dropped at once by bumping the version.
+func (c *Cache) feedPageKey(ctx context.Context, userID uuid.UUID, cursor string, limit int) (string, error) {
+        if c == nil || c.client == nil {
+                return "", fmt.Errorf("cache not available")
+        }
+        version, err := c.client.Get(ctx, fmt.Sprintf("feed_version:%s", userID.String())).Int64()
+        if err != nil && err != redis.Nil {
+                return "", err
+        }
+        if cursor == "" {
+                cursor = "first"
+        }
+        return fmt.Sprintf("feed:%s:v%d:%s:%d", userID.String(), version, cursor, limit), nil
+}
+
+func (c *Cache) GetFeedPage(ctx context.Context, userID uuid.UUID, cursor string, limit int, dest interface{}) error {
+        key, err := c.feedPageKey(ctx, userID, cursor, limit)
+        if err != nil {
+                return err
+        }
+        return c.Get(ctx, key, dest)
+}
+
+func (c *Cache) SetFeedPage(ctx context.Context, userID uuid.UUID, cursor string, limit int, page interface{}) error {
+        key, err := c.feedPageKey(ctx, userID, cursor, limit)
+        if err != nil {
+                return err
+        }
+        return c.Set(ctx, key, page, 30*time.Second)
+}
+
+func (c *Cache) InvalidateFeed(ctx context.Context, userIDs ...uuid.UUID) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        pipe := c.client.Pipeline()
+        for _, userID := range userIDs {
+                key := fmt.Sprintf("feed_version:%s", userID.String())
+                pipe.Incr(ctx, key)
+                pipe.Expire(ctx, key, 24*time.Hour)
+        }
+        _, err := pipe.Exec(ctx)
+        return err
+}
+
+// Timelines are sorted sets of story IDs scored by creation time in
+// microseconds, which matches the precision of Postgres timestamps so the
+// score order agrees with the feed's (created_at, id) ordering.
+type TimelineEntry struct {
+        StoryID   uuid.UUID
+        CreatedAt time.Time
+}
+
+func timelineKey(userID uuid.UUID) string {
+        return fmt.Sprintf("timeline:%s", userID.String())
+}
+
+// timelineTTL is how long a timeline and its floor live after the last push.
+// Idle timelines expire together with their floor, and the feed pulls
+// everything from Postgres until the next fan-out recreates them.
+const timelineTTL = 7 * 24 * time.Hour
+
+// FanOut pushes a story onto each user's timeline, creating missing ones,
+// and drops their cached feed pages in the same round trip.
+func (c *Cache) FanOut(ctx context.Context, entry TimelineEntry, userIDs []uuid.UUID) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        args := timelineArgs(true, []TimelineEntry{entry})
+        pipe := c.client.Pipeline()
+        for _, userID := range userIDs {
+                keys := []string{timelineKey(userID), timelineFloorKey(userID)}
+                pushTimeline.Eval(ctx, pipe, keys, args...)
+                versionKey := fmt.Sprintf("feed_version:%s", userID.String())
+                pipe.Incr(ctx, versionKey)
+                pipe.Expire(ctx, versionKey, 24*time.Hour)
+        }
+        _, err := pipe.Exec(ctx)
+        return err
+}
+
+// timelineFloorKey holds a timeline's watermark: no fanned-out story at or
+// after it is missing from the timeline, unless trimmed from the bottom. It
+// only moves up while the timeline exists.
+func timelineFloorKey(userID uuid.UUID) string {
+        return fmt.Sprintf("timeline_floor:%s", userID.String())
+}
+
+// pushTimeline adds the score/member pairs in ARGV[3..] to the timeline in
+// KEYS[1], first raising the watermark in KEYS[2] to the timeline's oldest
+// entry, so pushing older stories never lowers the floor. A missing timeline
+// is only created when ARGV[1] is "1"; everything fanned out to it before is
+// lost, so its watermark restarts at the current time. Both keys expire
+// ARGV[2] milliseconds after the push. It returns 0 when the timeline does
+// not exist and was not created.
+var pushTimeline = redis.NewScript(`
+local floor
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if #oldest == 0 then
+        if ARGV[1] ~= "1" then
+                return 0
+        end
+        local now = redis.call("TIME")
+        floor = tonumber(now[1]) * 1000000 + tonumber(now[2])
+else
+        floor = tonumber(oldest[2])
+        local watermark = tonumber(redis.call("GET", KEYS[2]))
+        if watermark and watermark > floor then
+                floor = watermark
+        end
+end
+redis.call("SET", KEYS[2], string.format("%d", floor), "PX", ARGV[2])
+redis.call("ZADD", KEYS[1], unpack(ARGV, 3))
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+func timelineArgs(create bool, entries []TimelineEntry) []interface{} {
+        flag := "0"
+        if create {
+                flag = "1"
+        }
+        args := make([]interface{}, 0, 2+2*len(entries))
+        args = append(args, flag, timelineTTL.Milliseconds())
+        for _, entry := range entries {
+                args = append(args, entry.CreatedAt.UnixMicro(), entry.StoryID.String())
+        }
+        return args
+}
+
+// BackfillTimeline pushes older stories onto an existing timeline, e.g. a
+// new followee's, without lowering its floor: the other followees' stories
+// between the backfilled ones and the old floor may have been trimmed.
+// Missing timelines are left alone, as the feed pulls everything for them.
+func (c *Cache) BackfillTimeline(ctx context.Context, userID uuid.UUID, entries []TimelineEntry) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        if len(entries) == 0 {
+                return nil
+        }
+        keys := []string{timelineKey(userID), timelineFloorKey(userID)}
+        return pushTimeline.Run(ctx, c.client, keys, timelineArgs(false, entries)...).Err()
+}
+
+// GetTimeline returns up to count story IDs from a user's timeline, newest
+// first, skipping offset entries at or before the given time.
+func (c *Cache) GetTimeline(ctx context.Context, userID uuid.UUID, before time.Time, offset, count int64) ([]uuid.UUID, error) {
+        if c == nil || c.client == nil {
+                return nil, fmt.Errorf("cache not available")
+        }
+        members, err := c.client.ZRevRangeByScore(ctx, timelineKey(userID), &redis.ZRangeBy{
+                Min:    "-inf",
+                Max:    strconv.FormatInt(before.UnixMicro(), 10),
+                Offset: offset,
+                Count:  count,
+        }).Result()
+        if err != nil {
+                return nil, err
+        }
+
+        storyIDs := make([]uuid.UUID, 0, len(members))
+        for _, member := range members {
+                storyID, err := uuid.Parse(member)
+                if err != nil {
+                        continue
+                }
+                storyIDs = append(storyIDs, storyID)
+        }
+        return storyIDs, nil
+}
+
+// TimelineFloor returns the time from which a user's timeline is complete:
+// the later of its oldest entry and its watermark. Trimming and
+// eviction only ever remove a timeline's oldest entries or the whole key,
+// and backfills cannot lower the watermark, so every fanned-out story at or
+// after the floor is on it. ok is false when the timeline does not exist,
+// e.g. after eviction or a Redis restart, in which case nothing can be
+// assumed to be on it.
+func (c *Cache) TimelineFloor(ctx context.Context, userID uuid.UUID) (time.Time, bool, error) {
+        if c == nil || c.client == nil {
+                return time.Time{}, false, fmt.Errorf("cache not available")
+        }
+        pipe := c.client.Pipeline()
+        oldest := pipe.ZRangeWithScores(ctx, timelineKey(userID), 0, 0)
+        watermark := pipe.Get(ctx, timelineFloorKey(userID))
+        if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+                return time.Time{}, false, err
+        }
+        if len(oldest.Val()) == 0 {
+                return time.Time{}, false, nil
+        }
+
+        floor := oldest.Val()[0].Score
+        if value, err := watermark.Float64(); err == nil && value > floor {
+                floor = value
+        }
+        return time.UnixMicro(int64(floor)), true, nil
+}
+
+// TrimTimelines removes entries created before cutoff from every timeline
+// and caps each one at maxLength entries. It returns how many were removed.
+func (c *Cache) TrimTimelines(ctx context.Context, cutoff time.Time, maxLength int64) (int64, error) {
+        if c == nil || c.client == nil {
+                return 0, fmt.Errorf("cache not available")
+        }
+        maxScore := "(" + strconv.FormatInt(cutoff.UnixMicro(), 10)
+
+        var removed int64
+        iter := c.client.Scan(ctx, 0, "timeline:*", 500).Iterator()
+        for iter.Next(ctx) {
+                pipe := c.client.Pipeline()
+                byScore := pipe.ZRemRangeByScore(ctx, iter.Val(), "-inf", maxScore)
+                byRank := pipe.ZRemRangeByRank(ctx, iter.Val(), 0, -maxLength-1)
+                if _, err := pipe.Exec(ctx); err != nil {
+                        return removed, err
+                }
+                removed += byScore.Val() + byRank.Val()
+        }
+        return removed, iter.Err()
+}
+
+var incrIfExists = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+        return redis.call("INCR", KEYS[1])
+end
+return 0
+`)
+
+// Presence sets hold the connections watching a story right now, as
+// WatcherMember strings, each scored by the Unix millisecond time it expires
+// unless refreshed. A user watching from several tabs or devices has one
+// member per connection and is counted once.
+func presenceKey(storyID uuid.UUID) string {
+        return fmt.Sprintf("presence:story:%s", storyID.String())
+}
+
+// presenceIndexKey lists every story with a presence set, scored by its
+// latest expiry, so expired watchers can be found without knowing which
+// stories to look at.
+const presenceIndexKey = "presence:stories"
+
+// WatcherMember identifies one connection in a presence set.
+func WatcherMember(userID uuid.UUID, connID string) string {
+        return userID.String() + ":" + connID
+}
+
+// distinctWatchers returns the users behind a set of WatcherMember strings.
+func distinctWatchers(members []string) []uuid.UUID {
+        seen := make(map[uuid.UUID]bool, len(members))
+        userIDs := make([]uuid.UUID, 0, len(members))
+        for _, member := range members {
+                raw, _, _ := strings.Cut(member, ":")
+                userID, err := uuid.Parse(raw)
+                if err != nil || seen[userID] {
+                        continue
+                }
+                seen[userID] = true
+                userIDs = append(userIDs, userID)
+        }
+        return userIDs
+}
+
+func liveWatchers(now time.Time) *redis.ZRangeBy {
+        return &redis.ZRangeBy{Min: "(" + strconv.FormatInt(now.UnixMilli(), 10), Max: "+inf"}
+}
+
+// WatchStory marks connections as watching a story for another ttl. It
+// returns how many users are watching and how many connections had expired
+// and were dropped.
+func (c *Cache) WatchStory(ctx context.Context, storyID uuid.UUID, members []string, ttl time.Duration) (int64, int64, error) {
+        if c == nil || c.client == nil {
+                return 0, 0, fmt.Errorf("cache not available")
+        }
+        key := presenceKey(storyID)
+        now := time.Now()
+        expiresAt := float64(now.Add(ttl).UnixMilli())
+
+        pipe := c.client.TxPipeline()
+        expired := pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+        if len(members) > 0 {
+                entries := make([]redis.Z, 0, len(members))
+                for _, member := range members {
+                        entries = append(entries, redis.Z{Score: expiresAt, Member: member})
+                }
+                pipe.ZAdd(ctx, key, entries...)
+                pipe.Expire(ctx, key, ttl)
+                pipe.ZAddGT(ctx, presenceIndexKey, redis.Z{Score: expiresAt, Member: storyID.String()})
+        }
+        live := pipe.ZRangeByScore(ctx, key, liveWatchers(now))
+        if _, err := pipe.Exec(ctx); err != nil {
+                return 0, 0, err
+        }
+        return int64(len(distinctWatchers(live.Val()))), expired.Val(), nil
+}
+
+// UnwatchStory removes one connection from a story's watchers and returns how
+// many users are left.
+func (c *Cache) UnwatchStory(ctx context.Context, storyID uuid.UUID, member string) (int64, error) {
+        if c == nil || c.client == nil {
+                return 0, fmt.Errorf("cache not available")
+        }
+        key := presenceKey(storyID)
+        now := time.Now()
+
+        pipe := c.client.TxPipeline()
+        pipe.ZRem(ctx, key, member)
+        live := pipe.ZRangeByScore(ctx, key, liveWatchers(now))
+        if _, err := pipe.Exec(ctx); err != nil {
+                return 0, err
+        }
+        return int64(len(distinctWatchers(live.Val()))), nil
+}
+
+// ExpireWatchers drops expired connections from every story's watchers. It
+// returns, for each story it dropped any from, how many users are still
+// watching. Removal is atomic per story, so when several replicas sweep at
+// once each expiry is reported by one of them only.
+func (c *Cache) ExpireWatchers(ctx context.Context) (map[uuid.UUID]int64, error) {
+        if c == nil || c.client == nil {
+                return nil, fmt.Errorf("cache not available")
+        }
+        now := time.Now()
+        cutoff := strconv.FormatInt(now.UnixMilli(), 10)
+
+        storyIDs, err := c.client.ZRange(ctx, presenceIndexKey, 0, -1).Result()
+        if err != nil {
+                return nil, err
+        }
+
+        pipe := c.client.Pipeline()
+        removed := make(map[uuid.UUID]*redis.IntCmd, len(storyIDs))
+        for _, raw := range storyIDs {
+                storyID, err := uuid.Parse(raw)
+                if err != nil {
+                        continue
+                }
+                removed[storyID] = pipe.ZRemRangeByScore(ctx, presenceKey(storyID), "-inf", cutoff)
+        }
+        if _, err := pipe.Exec(ctx); err != nil {
+                return nil, err
+        }
+
+        watching := make(map[uuid.UUID]int64)
+        for storyID, cmd := range removed {
+                if cmd.Val() == 0 {
+                        continue
+                }
+                live, err := c.client.ZRangeByScore(ctx, presenceKey(storyID), liveWatchers(now)).Result()
+                if err != nil {
+                        return nil, err
+                }
+                watching[storyID] = int64(len(distinctWatchers(live)))
+        }
+
+        // Stories whose latest watcher expired before this sweep started
+        // have just been emptied; a story watched again since has a newer
+        // score and stays.
+        if err := c.client.ZRemRangeByScore(ctx, presenceIndexKey, "-inf", cutoff).Err(); err != nil {
+                return nil, err
+        }
+        return watching, nil
+}
+
+// StoryWatchers returns the users currently watching a story.
+func (c *Cache) StoryWatchers(ctx context.Context, storyID uuid.UUID) ([]uuid.UUID, error) {
+        if c == nil || c.client == nil {
+                return nil, fmt.Errorf("cache not available")
+        }
+        members, err := c.client.ZRangeByScore(ctx, presenceKey(storyID), liveWatchers(time.Now())).Result()
+        if err != nil {
+                return nil, err
+        }
+        return distinctWatchers(members), nil
+}
+
+// ClearStoryWatchers removes every watcher from a story, as when it is
+// deleted, and returns the users who were watching.
+func (c *Cache) ClearStoryWatchers(ctx context.Context, storyID uuid.UUID) ([]uuid.UUID, error) {
+        if c == nil || c.client == nil {
+                return nil, fmt.Errorf("cache not available")
+        }
+        key := presenceKey(storyID)
+
+        pipe := c.client.TxPipeline()
+        live := pipe.ZRangeByScore(ctx, key, liveWatchers(time.Now()))
+        pipe.Del(ctx, key)
+        pipe.ZRem(ctx, presenceIndexKey, storyID.String())
+        if _, err := pipe.Exec(ctx); err != nil {
+                return nil, err
+        }
+        return distinctWatchers(live.Val()), nil
+}
+
+func (c *Cache) GetViewerCount(ctx context.Context, storyID uuid.UUID) (int64, error) {
+        if c == nil || c.client == nil {
+                return 0, fmt.Errorf("cache not available")
+        }
+        return c.client.Get(ctx, fmt.Sprintf("story_viewers:%s", storyID.String())).Int64()
+}
+
+func (c *Cache) SetViewerCount(ctx context.Context, storyID uuid.UUID, count int64) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        return c.client.Set(ctx, fmt.Sprintf("story_viewers:%s", storyID.String()), count, 10*time.Minute).Err()
+}
+
+// IncrViewerCount bumps a cached viewer count. Counts that are not cached are
+// left alone so the next read recomputes them from the database.
+func (c *Cache) IncrViewerCount(ctx context.Context, storyID uuid.UUID) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        key := fmt.Sprintf("story_viewers:%s", storyID.String())
+        return incrIfExists.Run(ctx, c.client, []string{key}).Err()
+}
+
+// RevokeToken blocks a single access token (by jti) until it would have
+// expired anyway.
+func (c *Cache) RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        return c.client.Set(ctx, fmt.Sprintf("revoked:jti:%s", tokenID), 1, ttl).Err()
+}
+
+// RevokeTokenFamily blocks every access token issued from a refresh token
+// family for as long as any of them could still be valid.
+func (c *Cache) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+        if c == nil || c.client == nil {
+                return fmt.Errorf("cache not available")
+        }
+        return c.client.Set(ctx, fmt.Sprintf("revoked:family:%s", familyID.String()), 1, ttl).Err()
+}
+
+func (c *Cache) IsTokenRevoked(ctx context.Context, tokenID string, familyID uuid.UUID) (bool, error) {
+        if c == nil || c.client == nil {
+                return false, nil
+        }
+        keys := []string{}
+        if tokenID != "" {
+                keys = append(keys, fmt.Sprintf("revoked:jti:%s", tokenID))
+        }
+        if familyID != uuid.Nil {
+                keys = append(keys, fmt.Sprintf("revoked:family:%s", familyID.String()))
+        }
+        if len(keys) == 0 {
+                return false, nil
+        }
+        count, err := c.client.Exists(ctx, keys...).Result()
+        if err != nil {
+                return false, err
+        }
+        return count > 0, nil
+}
+
 func (c *Cache) CheckRateLimit(ctx context.Context, userID uuid.UUID, action string, limit int, window time.Duration) (bool, error) {
         if c == nil || c.client == nil {
                 return true, nil
@@ -90,6 +561,12 @@ func (c *Cache) CheckRateLimit(ctx context.Context, userID uuid.UUID, action str
         return count <= int64(limit), nil
 }
 
+// Client exposes the underlying Redis client for components that need more
+// than caching, such as the WebSocket hub's pub/sub broker.
+func (c *Cache) Client() *redis.Client {
+        return c.client
+}
+
 func (c *Cache) Close() error {
         if c == nil || c.client == nil {
                 return nil
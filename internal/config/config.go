@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"stories-service/internal/reactions"
+)
+
+type StoryLifetime struct {
+	Default time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+// LoadStoryLifetime reads STORY_TTL, STORY_MIN_TTL and STORY_MAX_TTL as Go
+// durations (e.g. "24h"), falling back to a 24h default bounded by 1h..48h.
+func LoadStoryLifetime() (StoryLifetime, error) {
+	var lifetime StoryLifetime
+	var err error
+
+	if lifetime.Default, err = Duration("STORY_TTL", 24*time.Hour); err != nil {
+		return lifetime, err
+	}
+	if lifetime.Min, err = Duration("STORY_MIN_TTL", time.Hour); err != nil {
+		return lifetime, err
+	}
+	if lifetime.Max, err = Duration("STORY_MAX_TTL", 48*time.Hour); err != nil {
+		return lifetime, err
+	}
+
+	if lifetime.Min <= 0 || lifetime.Min > lifetime.Max {
+		return lifetime, fmt.Errorf("STORY_MIN_TTL must be positive and not exceed STORY_MAX_TTL")
+	}
+	if lifetime.Default < lifetime.Min || lifetime.Default > lifetime.Max {
+		return lifetime, fmt.Errorf("STORY_TTL must be between STORY_MIN_TTL and STORY_MAX_TTL")
+	}
+
+	return lifetime, nil
+}
+
+type TokenLifetime struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
+
+// LoadTokenLifetime reads ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL, defaulting
+// to 15 minutes and 30 days.
+func LoadTokenLifetime() (TokenLifetime, error) {
+	var lifetime TokenLifetime
+	var err error
+
+	if lifetime.Access, err = Duration("ACCESS_TOKEN_TTL", 15*time.Minute); err != nil {
+		return lifetime, err
+	}
+	if lifetime.Refresh, err = Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour); err != nil {
+		return lifetime, err
+	}
+
+	if lifetime.Access <= 0 || lifetime.Refresh <= lifetime.Access {
+		return lifetime, fmt.Errorf("REFRESH_TOKEN_TTL must be longer than a positive ACCESS_TOKEN_TTL")
+	}
+
+	return lifetime, nil
+}
+
+type FeedFanout struct {
+	MaxFollowers   int
+	TimelineLength int
+}
+
+// LoadFeedFanout reads FANOUT_MAX_FOLLOWERS, the follower count above which
+// an author's stories are pulled at read time instead of pushed to every
+// follower, and TIMELINE_MAX_LENGTH, the number of entries the worker keeps
+// in each Redis timeline.
+func LoadFeedFanout() (FeedFanout, error) {
+	var fanout FeedFanout
+	var err error
+
+	if fanout.MaxFollowers, err = Int("FANOUT_MAX_FOLLOWERS", 5000); err != nil {
+		return fanout, err
+	}
+	if fanout.TimelineLength, err = Int("TIMELINE_MAX_LENGTH", 1000); err != nil {
+		return fanout, err
+	}
+
+	if fanout.MaxFollowers < 0 || fanout.TimelineLength <= 0 {
+		return fanout, fmt.Errorf("FANOUT_MAX_FOLLOWERS must not be negative and TIMELINE_MAX_LENGTH must be positive")
+	}
+
+	return fanout, nil
+}
+
+// LoadReactions reads REACTIONS, a comma-separated list of emojis in display
+// order, falling back to the default palette.
+func LoadReactions() (*reactions.Catalogue, error) {
+	raw := os.Getenv("REACTIONS")
+	if raw == "" {
+		return reactions.NewCatalogue(reactions.Default)
+	}
+
+	catalogue, err := reactions.NewCatalogue(strings.Split(raw, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REACTIONS: %w", err)
+	}
+	return catalogue, nil
+}
+
+func Duration(key string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func Int(key string, fallback int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS reactions;
+DROP TABLE IF EXISTS story_views;
+DROP TABLE IF EXISTS story_audience;
+DROP TABLE IF EXISTS stories;
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS users;
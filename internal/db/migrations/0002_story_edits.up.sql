@@ -0,0 +1 @@
+ALTER TABLE stories ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS highlight_stories;
+DROP TABLE IF EXISTS highlights;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS highlights (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+	owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+	title TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS highlight_stories (
+	highlight_id UUID REFERENCES highlights(id) ON DELETE CASCADE,
+	story_id UUID REFERENCES stories(id) ON DELETE CASCADE,
+	added_at TIMESTAMPTZ DEFAULT NOW(),
+	PRIMARY KEY (highlight_id, story_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_highlights_owner ON highlights(owner_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_highlight_stories_story ON highlight_stories(story_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_story_views_story_viewed;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_story_views_story_viewed ON story_views(story_id, viewed_at DESC);
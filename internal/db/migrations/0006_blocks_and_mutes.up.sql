@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS blocks (
+	blocker_id UUID REFERENCES users(id) ON DELETE CASCADE,
+	blocked_id UUID REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE TABLE IF NOT EXISTS mutes (
+	muter_id UUID REFERENCES users(id) ON DELETE CASCADE,
+	muted_id UUID REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	PRIMARY KEY (muter_id, muted_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_blocks_blocked ON blocks(blocked_id);
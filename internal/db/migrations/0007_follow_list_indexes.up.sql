@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_follows_followee_created ON follows(followee_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_follows_follower_created ON follows(follower_id, created_at DESC);
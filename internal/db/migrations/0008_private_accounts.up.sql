@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE follows ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'accepted'
+	CHECK (status IN ('pending', 'accepted'));
+
+CREATE INDEX IF NOT EXISTS idx_follows_pending ON follows(followee_id, created_at DESC) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+UPDATE stories SET visibility = 'private' WHERE visibility = 'mutuals';
+ALTER TABLE stories DROP CONSTRAINT IF EXISTS stories_visibility_check;
+ALTER TABLE stories ADD CONSTRAINT stories_visibility_check
+	CHECK (visibility IN ('public', 'friends', 'private'));
@@ -0,0 +1,3 @@
+ALTER TABLE stories DROP CONSTRAINT IF EXISTS stories_visibility_check;
+ALTER TABLE stories ADD CONSTRAINT stories_visibility_check
+	CHECK (visibility IN ('public', 'friends', 'mutuals', 'private'));
@@ -0,0 +1 @@
+ALTER TABLE stories ADD COLUMN IF NOT EXISTS fanned_out BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reply_messages;
+DROP TABLE IF EXISTS reply_threads;
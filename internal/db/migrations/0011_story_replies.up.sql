@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reply_threads (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+	story_id UUID NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+	author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	viewer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_message_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (story_id, viewer_id)
+);
+
+CREATE TABLE IF NOT EXISTS reply_messages (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+	thread_id UUID NOT NULL REFERENCES reply_threads(id) ON DELETE CASCADE,
+	sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	text TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reply_threads_story ON reply_threads(story_id, last_message_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_reply_messages_thread ON reply_messages(thread_id, created_at DESC, id DESC);
@@ -0,0 +1 @@
+ALTER TABLE reactions DROP CONSTRAINT IF EXISTS reactions_story_user_key;
@@ -0,0 +1,14 @@
+-- Keep only each user's latest reaction per story before enforcing uniqueness.
+DELETE FROM reactions
+WHERE id IN (
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (
+			PARTITION BY story_id, user_id
+			ORDER BY created_at DESC NULLS LAST, id DESC
+		) AS rn
+		FROM reactions
+	) ranked
+	WHERE rn > 1
+);
+
+ALTER TABLE reactions ADD CONSTRAINT reactions_story_user_key UNIQUE (story_id, user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE story_views DROP COLUMN IF EXISTS tapped_through;
+ALTER TABLE story_views DROP COLUMN IF EXISTS completed;
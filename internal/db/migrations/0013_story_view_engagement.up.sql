@@ -0,0 +1,2 @@
+ALTER TABLE story_views ADD COLUMN IF NOT EXISTS completed BOOLEAN;
+ALTER TABLE story_views ADD COLUMN IF NOT EXISTS tapped_through BOOLEAN;
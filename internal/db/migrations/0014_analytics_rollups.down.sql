@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_reactions_created;
+DROP INDEX IF EXISTS idx_story_views_viewed;
+DROP INDEX IF EXISTS idx_stories_created;
+DROP TABLE IF EXISTS reaction_stats_hourly;
+DROP TABLE IF EXISTS author_viewers_hourly;
+DROP TABLE IF EXISTS author_stats_hourly;
+DROP TABLE IF EXISTS story_stats_hourly;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS story_stats_hourly (
+	story_id UUID NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+	author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	bucket TIMESTAMPTZ NOT NULL,
+	views INT NOT NULL DEFAULT 0,
+	reactions INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (story_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS author_stats_hourly (
+	author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	bucket TIMESTAMPTZ NOT NULL,
+	posted INT NOT NULL DEFAULT 0,
+	views INT NOT NULL DEFAULT 0,
+	reactions INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (author_id, bucket)
+);
+
+-- Distinct viewers per author and hour, so unique viewers over any window
+-- can be counted without touching story_views.
+CREATE TABLE IF NOT EXISTS author_viewers_hourly (
+	author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	bucket TIMESTAMPTZ NOT NULL,
+	viewer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	PRIMARY KEY (author_id, bucket, viewer_id)
+);
+
+CREATE TABLE IF NOT EXISTS reaction_stats_hourly (
+	story_id UUID NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+	author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	bucket TIMESTAMPTZ NOT NULL,
+	emoji TEXT NOT NULL,
+	reactions INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (story_id, bucket, emoji)
+);
+
+CREATE INDEX IF NOT EXISTS idx_story_stats_hourly_bucket ON story_stats_hourly(bucket);
+CREATE INDEX IF NOT EXISTS idx_author_stats_hourly_bucket ON author_stats_hourly(bucket);
+CREATE INDEX IF NOT EXISTS idx_author_viewers_hourly_bucket ON author_viewers_hourly(bucket);
+CREATE INDEX IF NOT EXISTS idx_reaction_stats_hourly_author ON reaction_stats_hourly(author_id, bucket);
+CREATE INDEX IF NOT EXISTS idx_reaction_stats_hourly_bucket ON reaction_stats_hourly(bucket);
+
+-- Rollup refreshes scan the raw tables by time.
+CREATE INDEX IF NOT EXISTS idx_stories_created ON stories(created_at);
+CREATE INDEX IF NOT EXISTS idx_story_views_viewed ON story_views(viewed_at);
+CREATE INDEX IF NOT EXISTS idx_reactions_created ON reactions(created_at);
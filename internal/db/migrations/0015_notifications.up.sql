@@ -0,0 +1,19 @@
+-- Per-user counter handing out notification sequence numbers. Incrementing it
+-- takes a row lock, so each user's numbers are gapless and commit in order.
+CREATE TABLE IF NOT EXISTS notification_seqs (
+	user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	last_seq BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	seq BIGINT NOT NULL,
+	type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	read_at TIMESTAMPTZ,
+	PRIMARY KEY (user_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id, seq) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notifications_created ON notifications(created_at);
@@ -0,0 +1,7 @@
+ALTER TABLE stories ADD COLUMN IF NOT EXISTS taken_down_at TIMESTAMPTZ;
+
+-- Before this column a deletion ahead of expires_at was the only sign of a
+-- takedown; deletions between expiry and the worker's sweep cannot be told
+-- apart and are left as expiries.
+UPDATE stories SET taken_down_at = deleted_at
+WHERE deleted_at IS NOT NULL AND deleted_at < expires_at AND taken_down_at IS NULL;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS story_views_rollup_delete ON story_views;
+DROP TRIGGER IF EXISTS reactions_rollup_delete ON reactions;
+DROP TRIGGER IF EXISTS reactions_rollup_update ON reactions;
+DROP FUNCTION IF EXISTS mark_view_hours_dirty();
+DROP FUNCTION IF EXISTS mark_reaction_hours_dirty();
+DROP TABLE IF EXISTS rollup_dirty_hours;
+DROP TABLE IF EXISTS rollup_state;
@@ -0,0 +1,57 @@
+-- High-water mark of the rows already folded into the hourly rollups. The
+-- worker only rebuilds buckets with rows written after it.
+CREATE TABLE IF NOT EXISTS rollup_state (
+	name TEXT PRIMARY KEY,
+	processed_to TIMESTAMPTZ NOT NULL
+);
+
+-- The rollup tables are empty when this is applied together with 0014, so the
+-- mark starts just before the first story and the worker builds the missing
+-- history itself. Views and reactions never predate their story.
+INSERT INTO rollup_state (name, processed_to)
+SELECT 'hourly', COALESCE(MIN(created_at) - INTERVAL '1 microsecond', NOW())
+FROM stories
+ON CONFLICT (name) DO NOTHING;
+
+-- Hours that lost or moved rows below the mark and must be rebuilt.
+CREATE TABLE IF NOT EXISTS rollup_dirty_hours (
+	bucket TIMESTAMPTZ PRIMARY KEY
+);
+
+-- DO UPDATE rather than DO NOTHING locks the row, so a refresh claiming the
+-- hour waits for this transaction and sees its change.
+CREATE OR REPLACE FUNCTION mark_reaction_hours_dirty() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO rollup_dirty_hours (bucket)
+	SELECT DISTINCT date_trunc('hour', created_at) FROM old_rows WHERE created_at IS NOT NULL
+	ON CONFLICT (bucket) DO UPDATE SET bucket = EXCLUDED.bucket;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION mark_view_hours_dirty() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO rollup_dirty_hours (bucket)
+	SELECT DISTINCT date_trunc('hour', viewed_at) FROM old_rows WHERE viewed_at IS NOT NULL
+	ON CONFLICT (bucket) DO UPDATE SET bucket = EXCLUDED.bucket;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Replacing a reaction moves it to the hour it was replaced in; removing one
+-- takes it out of its hour.
+DROP TRIGGER IF EXISTS reactions_rollup_update ON reactions;
+CREATE TRIGGER reactions_rollup_update AFTER UPDATE ON reactions
+	REFERENCING OLD TABLE AS old_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION mark_reaction_hours_dirty();
+
+DROP TRIGGER IF EXISTS reactions_rollup_delete ON reactions;
+CREATE TRIGGER reactions_rollup_delete AFTER DELETE ON reactions
+	REFERENCING OLD TABLE AS old_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION mark_reaction_hours_dirty();
+
+-- Views are only removed when a user or story is deleted.
+DROP TRIGGER IF EXISTS story_views_rollup_delete ON story_views;
+CREATE TRIGGER story_views_rollup_delete AFTER DELETE ON story_views
+	REFERENCING OLD TABLE AS old_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION mark_view_hours_dirty();
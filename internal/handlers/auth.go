@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"strings"
+	"time"
 
 	"stories-service/internal/auth"
+	"stories-service/internal/cache"
+	"stories-service/internal/config"
 	"stories-service/internal/db"
+	"stories-service/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -15,14 +20,18 @@ import (
 
 type AuthHandler struct {
 	db        *db.DB
+	cache     *cache.Cache
 	jwtSecret string
+	tokens    config.TokenLifetime
 	logger    *zap.Logger
 }
 
-func NewAuthHandler(database *db.DB, jwtSecret string, logger *zap.Logger) *AuthHandler {
+func NewAuthHandler(database *db.DB, cach *cache.Cache, jwtSecret string, tokens config.TokenLifetime, logger *zap.Logger) *AuthHandler {
 	return &AuthHandler{
 		db:        database,
+		cache:     cach,
 		jwtSecret: jwtSecret,
+		tokens:    tokens,
 		logger:    logger,
 	}
 }
@@ -37,10 +46,16 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 type AuthResponse struct {
-	Token  string    `json:"token"`
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int       `json:"expires_in"`
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
 }
 
 func (h *AuthHandler) Signup(c *gin.Context) {
@@ -75,20 +90,16 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	token, err := auth.GenerateToken(userID, req.Email, h.jwtSecret)
+	resp, err := h.issueTokens(h.db, userID, uuid.New(), req.Email)
 	if err != nil {
-		h.logger.Error("failed to generate token", zap.Error(err))
+		h.logger.Error("failed to issue tokens", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
 	h.logger.Info("user signed up", zap.String("user_id", userID.String()), zap.String("email", req.Email))
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token:  token,
-		UserID: userID,
-		Email:  req.Email,
-	})
+	c.JSON(http.StatusCreated, resp)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -122,18 +133,213 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := auth.GenerateToken(userID, req.Email, h.jwtSecret)
+	resp, err := h.issueTokens(h.db, userID, uuid.New(), req.Email)
 	if err != nil {
-		h.logger.Error("failed to generate token", zap.Error(err))
+		h.logger.Error("failed to issue tokens", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
 	h.logger.Info("user logged in", zap.String("user_id", userID.String()), zap.String("email", req.Email))
 
-	c.JSON(http.StatusOK, AuthResponse{
-		Token:  token,
-		UserID: userID,
-		Email:  req.Email,
-	})
+	c.JSON(http.StatusOK, resp)
+}
+
+// Refresh rotates a refresh token: the presented token is marked used and a
+// new one from the same family is returned. Presenting a token that was
+// already used or revoked means it leaked, so the whole family is revoked.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID, familyID uuid.UUID
+	var email string
+	var expiresAt time.Time
+	var usedAt, revokedAt *time.Time
+	err = tx.QueryRow(`
+		SELECT rt.id, rt.user_id, rt.family_id, u.email, rt.expires_at, rt.used_at, rt.revoked_at
+		FROM refresh_tokens rt
+		JOIN users u ON u.id = rt.user_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt
+	`, auth.HashRefreshToken(req.RefreshToken)).Scan(&tokenID, &userID, &familyID, &email,
+		&expiresAt, &usedAt, &revokedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to query refresh token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if usedAt != nil || revokedAt != nil {
+		if err := h.revokeFamily(c.Request.Context(), tx, familyID); err != nil {
+			h.logger.Error("failed to revoke token family", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			h.logger.Error("failed to commit transaction", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		h.logger.Warn("refresh token reuse detected",
+			zap.String("user_id", userID.String()),
+			zap.String("family_id", familyID.String()))
+
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected"})
+		return
+	}
+
+	if expiresAt.Before(time.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token expired"})
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1", tokenID); err != nil {
+		h.logger.Error("failed to mark refresh token used", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp, err := h.issueTokens(tx, userID, familyID, email)
+	if err != nil {
+		h.logger.Error("failed to issue tokens", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		h.logger.Error("failed to commit transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout revokes the calling access token and every refresh token in its
+// family.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, ok := middleware.GetTokenClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// Access tokens can only be revoked through Redis; without it the
+	// token stays valid until it expires, and the client is told so.
+	accessRevoked := false
+	if claims.ID != "" && h.cache != nil {
+		ttl := time.Until(claims.ExpiresAt.Time)
+		if err := h.cache.RevokeToken(c.Request.Context(), claims.ID, ttl); err != nil {
+			h.logger.Warn("failed to revoke access token", zap.Error(err))
+		} else {
+			accessRevoked = true
+		}
+	}
+
+	if claims.FamilyID != uuid.Nil {
+		tx, err := h.db.Begin()
+		if err != nil {
+			h.logger.Error("failed to begin transaction", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		defer tx.Rollback()
+
+		if err := h.revokeFamily(c.Request.Context(), tx, claims.FamilyID); err != nil {
+			h.logger.Error("failed to revoke token family", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			h.logger.Error("failed to commit transaction", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+
+	h.logger.Info("user logged out",
+		zap.String("user_id", claims.UserID.String()),
+		zap.Bool("access_token_revoked", accessRevoked))
+
+	if !accessRevoked {
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "logged out",
+			"access_token_revoked": false,
+			"warning":              "access token could not be revoked and stays valid until it expires",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out", "access_token_revoked": true})
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (h *AuthHandler) issueTokens(e execer, userID, familyID uuid.UUID, email string) (AuthResponse, error) {
+	accessToken, err := auth.GenerateToken(userID, familyID, email, h.jwtSecret, h.tokens.Access)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	_, err = e.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, userID, familyID, auth.HashRefreshToken(refreshToken), time.Now().Add(h.tokens.Refresh))
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	return AuthResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.tokens.Access.Seconds()),
+		UserID:       userID,
+		Email:        email,
+	}, nil
+}
+
+// revokeFamily revokes every refresh token in the family and blocks access
+// tokens already issued from it.
+func (h *AuthHandler) revokeFamily(ctx context.Context, tx *sql.Tx, familyID uuid.UUID) error {
+	_, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		return err
+	}
+
+	// Without Redis there is nothing to block; startup already warns that
+	// access tokens then live until they expire.
+	if h.cache == nil {
+		return nil
+	}
+	if err := h.cache.RevokeTokenFamily(ctx, familyID, h.tokens.Access); err != nil {
+		h.logger.Warn("failed to revoke access tokens for family", zap.Error(err))
+	}
+	return nil
 }
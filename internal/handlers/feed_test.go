@@ -0,0 +1,148 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"stories-service/internal/cache"
+	"stories-service/internal/db"
+	"stories-service/internal/pagination"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// newTestDB connects to and migrates the Postgres named by
+// TEST_DATABASE_URL, skipping the test when it is unset.
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	database, err := db.NewDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// newFeedTestHandler connects to the Postgres and Redis named by
+// TEST_DATABASE_URL and TEST_REDIS_ADDR, skipping the test when either is
+// unset.
+func newFeedTestHandler(t *testing.T) *StoriesHandler {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+	database := newTestDB(t)
+
+	redisCache, err := cache.NewCache(addr, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+
+	return &StoriesHandler{db: database, cache: redisCache, logger: zap.NewNop()}
+}
+
+func createTestStory(t *testing.T, database *db.DB, authorID uuid.UUID, createdAt time.Time) cache.TimelineEntry {
+	t.Helper()
+	entry := cache.TimelineEntry{StoryID: uuid.New(), CreatedAt: createdAt}
+	_, err := database.Exec(`
+		INSERT INTO stories (id, author_id, text, visibility, created_at, expires_at, fanned_out)
+		VALUES ($1, $2, 'hello', 'public', $3, $4, true)
+	`, entry.StoryID, authorID, createdAt, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func createTestFollow(t *testing.T, database *db.DB, followerID, followeeID uuid.UUID) {
+	t.Helper()
+	if _, err := database.Exec(`INSERT INTO follows (follower_id, followee_id, status) VALUES ($1, $2, 'accepted')`,
+		followerID, followeeID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createTestUser(t *testing.T, database *db.DB) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := database.Exec(`INSERT INTO users (id, email, password_hash) VALUES ($1, $2, 'x')`,
+		id, id.String()+"@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Exec(`DELETE FROM users WHERE id = $1`, id) })
+	return id
+}
+
+// TestFeedPullsStoriesMissingFromTimeline pushes a followee's stories to the
+// viewer's timeline, then loses part or all of it, and checks the feed still
+// returns every story.
+func TestFeedPullsStoriesMissingFromTimeline(t *testing.T) {
+	h := newFeedTestHandler(t)
+	social := &SocialHandler{db: h.db, cache: h.cache, logger: zap.NewNop()}
+	ctx := context.Background()
+
+	viewerID := createTestUser(t, h.db)
+	authorID := createTestUser(t, h.db)
+	createTestFollow(t, h.db, viewerID, authorID)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	want := 0
+	for i := 0; i < 3; i++ {
+		entry := createTestStory(t, h.db, authorID, now.Add(time.Duration(i-3)*time.Minute))
+		if err := h.cache.FanOut(ctx, entry, []uuid.UUID{viewerID}); err != nil {
+			t.Fatal(err)
+		}
+		want++
+	}
+
+	firstPage := pagination.Cursor{Time: now.Add(time.Hour), ID: uuid.Max}
+	assertFeed := func(stage string) {
+		t.Helper()
+		stories, err := h.feedStories(ctx, viewerID, firstPage, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stories) != want {
+			t.Fatalf("%s: feed has %d stories, want %d", stage, len(stories), want)
+		}
+	}
+
+	assertFeed("full timeline")
+
+	if _, err := h.cache.TrimTimelines(ctx, now.Add(-time.Hour), 1); err != nil {
+		t.Fatal(err)
+	}
+	assertFeed("trimmed timeline")
+
+	// Following someone whose stories are older than the trimmed ones
+	// backfills them below the timeline's oldest entry.
+	olderID := createTestUser(t, h.db)
+	for i := 0; i < 2; i++ {
+		createTestStory(t, h.db, olderID, now.Add(time.Duration(i-10)*time.Minute))
+		want++
+	}
+	createTestFollow(t, h.db, viewerID, olderID)
+	social.backfillTimeline(ctx, viewerID, olderID)
+	assertFeed("backfilled timeline")
+
+	if err := h.cache.Delete(ctx, "timeline:"+viewerID.String()); err != nil {
+		t.Fatal(err)
+	}
+	assertFeed("deleted timeline")
+}
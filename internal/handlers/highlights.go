@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"stories-service/internal/db"
+	"stories-service/internal/middleware"
+	"stories-service/internal/models"
+	"stories-service/internal/visibility"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Both expiry and the author deleting a story set deleted_at; only the author
+// sets taken_down_at, which also removes the story from highlights.
+const notTakenDown = "s.taken_down_at IS NULL"
+
+type HighlightsHandler struct {
+	db     *db.DB
+	logger *zap.Logger
+}
+
+func NewHighlightsHandler(database *db.DB, logger *zap.Logger) *HighlightsHandler {
+	return &HighlightsHandler{
+		db:     database,
+		logger: logger,
+	}
+}
+
+func (h *HighlightsHandler) CreateHighlight(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.HighlightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	highlight := models.Highlight{OwnerID: userID, Title: req.Title}
+	err := h.db.QueryRow(`
+		INSERT INTO highlights (owner_id, title)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, userID, req.Title).Scan(&highlight.ID, &highlight.CreatedAt, &highlight.UpdatedAt)
+
+	if err != nil {
+		h.logger.Error("failed to create highlight", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.logger.Info("highlight created",
+		zap.String("highlight_id", highlight.ID.String()),
+		zap.String("owner_id", userID.String()))
+
+	c.JSON(http.StatusCreated, highlight)
+}
+
+func (h *HighlightsHandler) ListUserHighlights(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ownerIDStr := c.Param("id")
+	ownerID, err := uuid.Parse(ownerIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT h.id, h.owner_id, h.title, h.created_at, h.updated_at,
+		  (SELECT COUNT(*)
+		   FROM highlight_stories hs
+		   JOIN stories s ON s.id = hs.story_id
+		   WHERE hs.highlight_id = h.id
+		     AND `+notTakenDown+`
+		     AND `+visibility.Predicate("s", "$1")+`) AS story_count
+		FROM highlights h
+		WHERE h.owner_id = $2
+		ORDER BY h.created_at DESC
+	`, userID, ownerID)
+
+	if err != nil {
+		h.logger.Error("failed to list highlights", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	highlights := []models.Highlight{}
+	for rows.Next() {
+		var highlight models.Highlight
+		err := rows.Scan(&highlight.ID, &highlight.OwnerID, &highlight.Title,
+			&highlight.CreatedAt, &highlight.UpdatedAt, &highlight.StoryCount)
+		if err != nil {
+			h.logger.Error("failed to scan highlight", zap.Error(err))
+			continue
+		}
+		if highlight.StoryCount == 0 && ownerID != userID {
+			continue
+		}
+		highlights = append(highlights, highlight)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate highlights", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"highlights": highlights})
+}
+
+func (h *HighlightsHandler) GetHighlight(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	highlightIDStr := c.Param("id")
+	highlightID, err := uuid.Parse(highlightIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid highlight id"})
+		return
+	}
+
+	highlight, ok := h.loadHighlight(c, highlightID)
+	if !ok {
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT s.id, s.author_id, s.text, s.media_key, s.visibility, s.created_at, s.expires_at, s.edited_at
+		FROM highlight_stories hs
+		JOIN stories s ON s.id = hs.story_id
+		WHERE hs.highlight_id = $2
+		  AND `+notTakenDown+`
+		  AND `+visibility.Predicate("s", "$1")+`
+		ORDER BY s.created_at ASC
+	`, userID, highlightID)
+
+	if err != nil {
+		h.logger.Error("failed to get highlight stories", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	highlight.Stories = []models.Story{}
+	for rows.Next() {
+		var story models.Story
+		err := rows.Scan(&story.ID, &story.AuthorID, &story.Text, &story.MediaKey,
+			&story.Visibility, &story.CreatedAt, &story.ExpiresAt, &story.EditedAt)
+		if err != nil {
+			h.logger.Error("failed to scan story", zap.Error(err))
+			continue
+		}
+		highlight.Stories = append(highlight.Stories, story)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate highlight stories", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	highlight.StoryCount = len(highlight.Stories)
+
+	if highlight.StoryCount == 0 && highlight.OwnerID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "highlight not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, highlight)
+}
+
+func (h *HighlightsHandler) UpdateHighlight(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	highlightIDStr := c.Param("id")
+	highlightID, err := uuid.Parse(highlightIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid highlight id"})
+		return
+	}
+
+	var req models.HighlightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	highlight, ok := h.loadOwnedHighlight(c, userID, highlightID)
+	if !ok {
+		return
+	}
+
+	highlight.Title = req.Title
+	err = h.db.QueryRow(`
+		UPDATE highlights SET title = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`, highlightID, req.Title).Scan(&highlight.UpdatedAt)
+
+	if err != nil {
+		h.logger.Error("failed to update highlight", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, highlight)
+}
+
+func (h *HighlightsHandler) DeleteHighlight(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	highlightIDStr := c.Param("id")
+	highlightID, err := uuid.Parse(highlightIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid highlight id"})
+		return
+	}
+
+	if _, ok := h.loadOwnedHighlight(c, userID, highlightID); !ok {
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM highlights WHERE id = $1", highlightID); err != nil {
+		h.logger.Error("failed to delete highlight", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.logger.Info("highlight deleted",
+		zap.String("highlight_id", highlightID.String()),
+		zap.String("owner_id", userID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "highlight deleted"})
+}
+
+func (h *HighlightsHandler) AddStory(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	highlightIDStr := c.Param("id")
+	highlightID, err := uuid.Parse(highlightIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid highlight id"})
+		return
+	}
+
+	var req models.AddHighlightStoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := h.loadOwnedHighlight(c, userID, highlightID); !ok {
+		return
+	}
+
+	var isOwnStory bool
+	err = h.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM stories s
+			WHERE s.id = $1 AND s.author_id = $2 AND `+notTakenDown+`
+		)
+	`, req.StoryID, userID).Scan(&isOwnStory)
+
+	if err != nil {
+		h.logger.Error("failed to check story ownership", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !isOwnStory {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO highlight_stories (highlight_id, story_id)
+		VALUES ($1, $2)
+		ON CONFLICT (highlight_id, story_id) DO NOTHING
+	`, highlightID, req.StoryID)
+	if err == nil {
+		_, err = tx.Exec("UPDATE highlights SET updated_at = NOW() WHERE id = $1", highlightID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		h.logger.Error("failed to add story to highlight", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.logger.Info("story added to highlight",
+		zap.String("highlight_id", highlightID.String()),
+		zap.String("story_id", req.StoryID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "story added to highlight"})
+}
+
+func (h *HighlightsHandler) RemoveStory(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	highlightIDStr := c.Param("id")
+	highlightID, err := uuid.Parse(highlightIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid highlight id"})
+		return
+	}
+
+	storyIDStr := c.Param("story_id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	if _, ok := h.loadOwnedHighlight(c, userID, highlightID); !ok {
+		return
+	}
+
+	_, err = h.db.Exec(`
+		DELETE FROM highlight_stories
+		WHERE highlight_id = $1 AND story_id = $2
+	`, highlightID, storyID)
+
+	if err != nil {
+		h.logger.Error("failed to remove story from highlight", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "story removed from highlight"})
+}
+
+func (h *HighlightsHandler) loadHighlight(c *gin.Context, highlightID uuid.UUID) (models.Highlight, bool) {
+	var highlight models.Highlight
+	err := h.db.QueryRow(`
+		SELECT id, owner_id, title, created_at, updated_at
+		FROM highlights
+		WHERE id = $1
+	`, highlightID).Scan(&highlight.ID, &highlight.OwnerID, &highlight.Title,
+		&highlight.CreatedAt, &highlight.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "highlight not found"})
+		return highlight, false
+	}
+	if err != nil {
+		h.logger.Error("failed to get highlight", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return highlight, false
+	}
+
+	return highlight, true
+}
+
+func (h *HighlightsHandler) loadOwnedHighlight(c *gin.Context, userID, highlightID uuid.UUID) (models.Highlight, bool) {
+	highlight, ok := h.loadHighlight(c, highlightID)
+	if !ok {
+		return highlight, false
+	}
+
+	if highlight.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return highlight, false
+	}
+
+	return highlight, true
+}
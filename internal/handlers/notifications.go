@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"stories-service/internal/db"
+	"stories-service/internal/middleware"
+	"stories-service/internal/models"
+	"stories-service/internal/notifications"
+	"stories-service/internal/pagination"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NotificationsHandler serves the user's event inbox: every event the hub has
+// sent them, whether or not a socket was open at the time.
+type NotificationsHandler struct {
+	db     *db.DB
+	inbox  *notifications.Store
+	logger *zap.Logger
+}
+
+func NewNotificationsHandler(database *db.DB, inbox *notifications.Store, logger *zap.Logger) *NotificationsHandler {
+	return &NotificationsHandler{
+		db:     database,
+		inbox:  inbox,
+		logger: logger,
+	}
+}
+
+func (h *NotificationsHandler) ListNotifications(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	beforeSeq := int64(math.MaxInt64)
+	if raw := c.Query("before_seq"); raw != "" {
+		beforeSeq, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeSeq < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before_seq must be a positive integer"})
+			return
+		}
+	}
+
+	unreadOnly := false
+	if raw := c.Query("unread_only"); raw != "" {
+		unreadOnly, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unread_only must be a boolean"})
+			return
+		}
+	}
+
+	rows, err := h.db.Query(`
+		SELECT seq, type, payload, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1
+		  AND seq < $2
+		  AND (NOT $3 OR read_at IS NULL)
+		ORDER BY seq DESC
+		LIMIT $4
+	`, userID, beforeSeq, unreadOnly, limit+1)
+
+	if err != nil {
+		h.logger.Error("failed to list notifications", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var payload []byte
+		if err := rows.Scan(&n.Seq, &n.Type, &payload, &n.CreatedAt, &n.ReadAt); err != nil {
+			h.logger.Error("failed to scan notification", zap.Error(err))
+			continue
+		}
+		n.Payload = json.RawMessage(payload)
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate notifications", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := models.NotificationsResponse{Notifications: notifications}
+	if len(notifications) > limit {
+		resp.Notifications = notifications[:limit]
+		next := resp.Notifications[limit-1].Seq
+		resp.NextBeforeSeq = &next
+	}
+
+	err = h.db.QueryRow(`
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+	`, userID).Scan(&resp.UnreadCount)
+
+	if err != nil {
+		h.logger.Error("failed to count unread notifications", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// MarkRead marks every notification up to and including up_to_seq as read,
+// the same as an ack frame on the WebSocket.
+func (h *NotificationsHandler) MarkRead(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.MarkNotificationsReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	marked, err := h.inbox.MarkRead(c.Request.Context(), userID, req.UpToSeq)
+	if err != nil {
+		h.logger.Error("failed to mark notifications read", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": marked})
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"stories-service/internal/cache"
+	"stories-service/internal/db"
+	"stories-service/internal/middleware"
+	"stories-service/internal/models"
+	"stories-service/internal/pagination"
+	"stories-service/internal/visibility"
+	"stories-service/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RepliesHandler serves text replies to stories. Each viewer who replies to a
+// story gets one private thread with its author; the thread outlives the
+// story so the conversation can continue after it expires.
+type RepliesHandler struct {
+	db      *db.DB
+	cache   *cache.Cache
+	hub     *websocket.Hub
+	cursors *pagination.Codec
+	access  *visibility.Evaluator
+	logger  *zap.Logger
+}
+
+func NewRepliesHandler(database *db.DB, cach *cache.Cache, hub *websocket.Hub, cursors *pagination.Codec, logger *zap.Logger) *RepliesHandler {
+	return &RepliesHandler{
+		db:      database,
+		cache:   cach,
+		hub:     hub,
+		cursors: cursors,
+		access:  visibility.NewEvaluator(database),
+		logger:  logger,
+	}
+}
+
+func (h *RepliesHandler) CreateReply(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	text, ok := bindReplyText(c)
+	if !ok {
+		return
+	}
+
+	if !h.checkRateLimit(c, userID) {
+		return
+	}
+
+	var authorID uuid.UUID
+	err = h.db.QueryRow(`
+		SELECT author_id FROM stories
+		WHERE id = $1 AND deleted_at IS NULL AND expires_at > NOW()
+	`, storyID).Scan(&authorID)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get story", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if authorID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot reply to your own story"})
+		return
+	}
+
+	canView, err := h.access.CanView(c.Request.Context(), userID, storyID)
+	if err != nil {
+		h.logger.Error("failed to check story visibility", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var threadID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO reply_threads (story_id, author_id, viewer_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (story_id, viewer_id) DO UPDATE SET last_message_at = NOW()
+		RETURNING id
+	`, storyID, authorID, userID).Scan(&threadID)
+
+	if err != nil {
+		h.logger.Error("failed to create reply thread", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	message, err := insertReplyMessage(c.Request.Context(), tx, threadID, userID, text)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.logger.Error("failed to create reply", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.notifyReply(authorID, storyID, message)
+
+	h.logger.Info("story reply sent",
+		zap.String("story_id", storyID.String()),
+		zap.String("thread_id", threadID.String()),
+		zap.String("sender_id", userID.String()))
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// ListStoryReplies lists the reply threads on a story, most recently active
+// first, each with its latest message (author only).
+func (h *RepliesHandler) ListStoryReplies(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor := pagination.Cursor{Time: time.Now().Add(time.Hour), ID: uuid.Max}
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		cursor, err = h.cursors.Decode(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	var authorID uuid.UUID
+	err = h.db.QueryRow("SELECT author_id FROM stories WHERE id = $1", storyID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get story", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if authorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT t.id, t.story_id, t.author_id, t.viewer_id, t.created_at, t.last_message_at,
+		  m.id, m.sender_id, m.text, m.created_at
+		FROM reply_threads t
+		JOIN LATERAL (
+		  SELECT id, sender_id, text, created_at FROM reply_messages
+		  WHERE thread_id = t.id
+		  ORDER BY created_at DESC, id DESC
+		  LIMIT 1
+		) m ON true
+		WHERE t.story_id = $1
+		  AND (t.last_message_at, t.id) < ($2, $3)
+		ORDER BY t.last_message_at DESC, t.id DESC
+		LIMIT $4
+	`, storyID, cursor.Time, cursor.ID, limit+1)
+
+	if err != nil {
+		h.logger.Error("failed to get reply threads", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	threads := []models.ReplyThread{}
+	for rows.Next() {
+		var thread models.ReplyThread
+		var message models.ReplyMessage
+		err := rows.Scan(&thread.ID, &thread.StoryID, &thread.AuthorID, &thread.ViewerID,
+			&thread.CreatedAt, &thread.LastMessageAt,
+			&message.ID, &message.SenderID, &message.Text, &message.CreatedAt)
+		if err != nil {
+			h.logger.Error("failed to scan reply thread", zap.Error(err))
+			continue
+		}
+		message.ThreadID = thread.ID
+		thread.LastMessage = &message
+		threads = append(threads, thread)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate reply threads", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := models.ReplyThreadsResponse{Threads: threads}
+	if len(threads) > limit {
+		resp.Threads = threads[:limit]
+		last := resp.Threads[limit-1]
+		next := h.cursors.Encode(pagination.Cursor{Time: last.LastMessageAt, ID: last.ID})
+		resp.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListThreadMessages returns a thread's messages, newest first, to either
+// participant.
+func (h *RepliesHandler) ListThreadMessages(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	threadIDStr := c.Param("id")
+	threadID, err := uuid.Parse(threadIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor := pagination.Cursor{Time: time.Now().Add(time.Hour), ID: uuid.Max}
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		cursor, err = h.cursors.Decode(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	if _, ok := h.loadThread(c, userID, threadID); !ok {
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, sender_id, text, created_at
+		FROM reply_messages
+		WHERE thread_id = $1
+		  AND (created_at, id) < ($2, $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`, threadID, cursor.Time, cursor.ID, limit+1)
+
+	if err != nil {
+		h.logger.Error("failed to get reply messages", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	messages := []models.ReplyMessage{}
+	for rows.Next() {
+		message := models.ReplyMessage{ThreadID: threadID}
+		if err := rows.Scan(&message.ID, &message.SenderID, &message.Text, &message.CreatedAt); err != nil {
+			h.logger.Error("failed to scan reply message", zap.Error(err))
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate reply messages", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := models.ReplyMessagesResponse{Messages: messages}
+	if len(messages) > limit {
+		resp.Messages = messages[:limit]
+		last := resp.Messages[limit-1]
+		next := h.cursors.Encode(pagination.Cursor{Time: last.CreatedAt, ID: last.ID})
+		resp.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// SendThreadMessage lets either participant continue an existing thread,
+// even after the story has expired, unless one has blocked the other.
+func (h *RepliesHandler) SendThreadMessage(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	threadIDStr := c.Param("id")
+	threadID, err := uuid.Parse(threadIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+		return
+	}
+
+	text, ok := bindReplyText(c)
+	if !ok {
+		return
+	}
+
+	thread, ok := h.loadThread(c, userID, threadID)
+	if !ok {
+		return
+	}
+
+	if !h.checkRateLimit(c, userID) {
+		return
+	}
+
+	recipientID := thread.AuthorID
+	if userID == thread.AuthorID {
+		recipientID = thread.ViewerID
+	}
+
+	var blocked bool
+	err = h.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`, userID, recipientID).Scan(&blocked)
+	if err != nil {
+		h.logger.Error("failed to check blocks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot message this user"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE reply_threads SET last_message_at = NOW() WHERE id = $1", threadID)
+	var message models.ReplyMessage
+	if err == nil {
+		message, err = insertReplyMessage(c.Request.Context(), tx, threadID, userID, text)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.logger.Error("failed to send reply", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.notifyReply(recipientID, thread.StoryID, message)
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// loadThread fetches a reply thread and checks that the caller is one of its
+// two participants. On failure it writes the error response and returns false.
+func (h *RepliesHandler) loadThread(c *gin.Context, userID, threadID uuid.UUID) (models.ReplyThread, bool) {
+	var thread models.ReplyThread
+	err := h.db.QueryRow(`
+		SELECT id, story_id, author_id, viewer_id, created_at, last_message_at
+		FROM reply_threads
+		WHERE id = $1
+	`, threadID).Scan(&thread.ID, &thread.StoryID, &thread.AuthorID, &thread.ViewerID,
+		&thread.CreatedAt, &thread.LastMessageAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "thread not found"})
+		return thread, false
+	}
+	if err != nil {
+		h.logger.Error("failed to get reply thread", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return thread, false
+	}
+
+	if userID != thread.AuthorID && userID != thread.ViewerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return thread, false
+	}
+
+	return thread, true
+}
+
+func (h *RepliesHandler) checkRateLimit(c *gin.Context, userID uuid.UUID) bool {
+	allowed, err := h.cache.CheckRateLimit(c.Request.Context(), userID, "reply", 30, time.Minute)
+	if err != nil {
+		h.logger.Error("rate limit check failed", zap.Error(err))
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return false
+	}
+	return true
+}
+
+func (h *RepliesHandler) notifyReply(recipientID, storyID uuid.UUID, message models.ReplyMessage) {
+	h.hub.SendToUser(recipientID, websocket.Event{
+		Type: "story.replied",
+		Payload: websocket.StoryRepliedEvent{
+			ThreadID:  message.ThreadID,
+			StoryID:   storyID,
+			MessageID: message.ID,
+			SenderID:  message.SenderID,
+			Text:      message.Text,
+			CreatedAt: message.CreatedAt.Format(time.RFC3339),
+		},
+	})
+}
+
+func bindReplyText(c *gin.Context) (string, bool) {
+	var req models.ReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text required"})
+		return "", false
+	}
+	return text, true
+}
+
+func insertReplyMessage(ctx context.Context, tx *sql.Tx, threadID, senderID uuid.UUID, text string) (models.ReplyMessage, error) {
+	message := models.ReplyMessage{ThreadID: threadID, SenderID: senderID, Text: text}
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO reply_messages (thread_id, sender_id, text)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, threadID, senderID, text).Scan(&message.ID, &message.CreatedAt)
+	return message, err
+}
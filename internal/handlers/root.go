@@ -15,26 +15,66 @@ func RootHandler(c *gin.Context) {
 			"auth": []string{
 				"POST /signup",
 				"POST /login",
+				"POST /token/refresh",
+				"POST /logout",
 			},
 			"stories": []string{
 				"POST /stories",
 				"GET /stories/:id",
+				"PATCH /stories/:id",
+				"DELETE /stories/:id",
 				"GET /feed",
+				"GET /feed?mode=grouped",
 				"POST /stories/:id/view",
+				"GET /stories/:id/viewers",
+				"GET /stories/:id/viewers/count",
+				"GET /stories/:id/insights",
+				"GET /stories/:id/presence",
 				"POST /stories/:id/reactions",
+				"DELETE /stories/:id/reactions",
+				"GET /reactions/catalogue",
+			},
+			"replies": []string{
+				"POST /stories/:id/replies",
+				"GET /stories/:id/replies",
+				"GET /reply-threads/:id/messages",
+				"POST /reply-threads/:id/messages",
 			},
 			"social": []string{
 				"POST /follow/:user_id",
 				"DELETE /follow/:user_id",
+				"POST /block/:user_id",
+				"DELETE /block/:user_id",
+				"POST /mute/:user_id",
+				"DELETE /mute/:user_id",
+				"GET /users/:id",
+				"GET /users/:id/followers",
+				"GET /users/:id/following",
+			},
+			"highlights": []string{
+				"POST /highlights",
+				"GET /highlights/:id",
+				"PATCH /highlights/:id",
+				"DELETE /highlights/:id",
+				"POST /highlights/:id/stories",
+				"DELETE /highlights/:id/stories/:story_id",
+				"GET /users/:id/highlights",
 			},
 			"user": []string{
 				"GET /me/stats",
+				"GET /me/notifications",
+				"POST /me/notifications/read",
+				"PUT /me/privacy",
+				"GET /me/follow-requests",
+				"POST /me/follow-requests/:user_id/approve",
+				"DELETE /me/follow-requests/:user_id",
 			},
 			"upload": []string{
 				"POST /upload/presigned",
 			},
-			"websocket": []string{
+			"realtime": []string{
 				"GET /ws",
+				"GET /events",
 			},
 			"system": []string{
 				"GET /healthz",
@@ -1,25 +1,44 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"net/http"
+	"time"
 
+	"stories-service/internal/cache"
 	"stories-service/internal/db"
 	"stories-service/internal/middleware"
+	"stories-service/internal/models"
+	"stories-service/internal/pagination"
+	"stories-service/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+const (
+	followPending  = "pending"
+	followAccepted = "accepted"
+)
+
 type SocialHandler struct {
-	db     *db.DB
-	logger *zap.Logger
+	db      *db.DB
+	cache   *cache.Cache
+	hub     *websocket.Hub
+	cursors *pagination.Codec
+	logger  *zap.Logger
 }
 
-func NewSocialHandler(database *db.DB, logger *zap.Logger) *SocialHandler {
+func NewSocialHandler(database *db.DB, cach *cache.Cache, hub *websocket.Hub, cursors *pagination.Codec, logger *zap.Logger) *SocialHandler {
 	return &SocialHandler{
-		db:     database,
-		logger: logger,
+		db:      database,
+		cache:   cach,
+		hub:     hub,
+		cursors: cursors,
+		logger:  logger,
 	}
 }
 
@@ -42,11 +61,43 @@ func (h *SocialHandler) Follow(c *gin.Context) {
 		return
 	}
 
-	_, err = h.db.Exec(`
-		INSERT INTO follows (follower_id, followee_id)
-		VALUES ($1, $2)
-		ON CONFLICT (follower_id, followee_id) DO NOTHING
-	`, userID, followeeID)
+	var isPrivate, blocked bool
+	err = h.db.QueryRow(`
+		SELECT u.is_private, EXISTS(
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2)
+			   OR (blocker_id = $2 AND blocked_id = $1)
+		)
+		FROM users u
+		WHERE u.id = $2
+	`, userID, followeeID).Scan(&isPrivate, &blocked)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to check followee", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot follow this user"})
+		return
+	}
+
+	status := followAccepted
+	if isPrivate {
+		status = followPending
+	}
+
+	var inserted bool
+	err = h.db.QueryRow(`
+		INSERT INTO follows (follower_id, followee_id, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (follower_id, followee_id) DO UPDATE SET status = follows.status
+		RETURNING status, (xmax = 0)
+	`, userID, followeeID, status).Scan(&status, &inserted)
 
 	if err != nil {
 		h.logger.Error("failed to follow user", zap.Error(err))
@@ -54,11 +105,34 @@ func (h *SocialHandler) Follow(c *gin.Context) {
 		return
 	}
 
+	if status == followPending {
+		if inserted {
+			h.hub.SendToUser(followeeID, websocket.Event{
+				Type: "follow.requested",
+				Payload: websocket.FollowEvent{
+					FollowerID: userID,
+					FolloweeID: followeeID,
+				},
+			})
+		}
+
+		h.logger.Info("follow requested",
+			zap.String("follower_id", userID.String()),
+			zap.String("followee_id", followeeID.String()))
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "follow requested", "status": status})
+		return
+	}
+
+	h.backfillTimeline(c.Request.Context(), userID, followeeID)
+	h.cache.InvalidateFeed(c.Request.Context(), userID)
+	h.cache.InvalidateFollowees(c.Request.Context(), userID)
+
 	h.logger.Info("user followed",
 		zap.String("follower_id", userID.String()),
 		zap.String("followee_id", followeeID.String()))
 
-	c.JSON(http.StatusOK, gin.H{"message": "followed successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "followed successfully", "status": status})
 }
 
 func (h *SocialHandler) Unfollow(c *gin.Context) {
@@ -86,9 +160,735 @@ func (h *SocialHandler) Unfollow(c *gin.Context) {
 		return
 	}
 
+	h.cache.InvalidateFeed(c.Request.Context(), userID)
+	h.cache.InvalidateFollowees(c.Request.Context(), userID)
+	// Unfollowing a private author ends access to their stories, and
+	// breaking the mutual follow ends the followee's access to the
+	// unfollower's mutuals stories.
+	h.hub.RecheckUser(userID)
+	h.hub.RecheckUser(followeeID)
+
 	h.logger.Info("user unfollowed",
 		zap.String("follower_id", userID.String()),
 		zap.String("followee_id", followeeID.String()))
 
 	c.JSON(http.StatusOK, gin.H{"message": "unfollowed successfully"})
 }
+
+// Block hides both users' stories from each other and removes any follow
+// relationship between them.
+func (h *SocialHandler) Block(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	blockedIDStr := c.Param("user_id")
+	blockedID, err := uuid.Parse(blockedIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if userID == blockedID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot block yourself"})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO blocks (blocker_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, userID, blockedID)
+	if err == nil {
+		_, err = tx.Exec(`
+			DELETE FROM follows
+			WHERE (follower_id = $1 AND followee_id = $2)
+			   OR (follower_id = $2 AND followee_id = $1)
+		`, userID, blockedID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		h.logger.Error("failed to block user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.cache.InvalidateFeed(c.Request.Context(), userID, blockedID)
+	h.cache.InvalidateFollowees(c.Request.Context(), userID, blockedID)
+	h.hub.RecheckUser(userID)
+	h.hub.RecheckUser(blockedID)
+
+	h.logger.Info("user blocked",
+		zap.String("blocker_id", userID.String()),
+		zap.String("blocked_id", blockedID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "blocked successfully"})
+}
+
+func (h *SocialHandler) Unblock(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	blockedIDStr := c.Param("user_id")
+	blockedID, err := uuid.Parse(blockedIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	_, err = h.db.Exec(`
+		DELETE FROM blocks
+		WHERE blocker_id = $1 AND blocked_id = $2
+	`, userID, blockedID)
+
+	if err != nil {
+		h.logger.Error("failed to unblock user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.cache.InvalidateFeed(c.Request.Context(), userID, blockedID)
+
+	h.logger.Info("user unblocked",
+		zap.String("blocker_id", userID.String()),
+		zap.String("blocked_id", blockedID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "unblocked successfully"})
+}
+
+// Mute hides a user's stories from the caller's feed without unfollowing.
+func (h *SocialHandler) Mute(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	mutedIDStr := c.Param("user_id")
+	mutedID, err := uuid.Parse(mutedIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if userID == mutedID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot mute yourself"})
+		return
+	}
+
+	_, err = h.db.Exec(`
+		INSERT INTO mutes (muter_id, muted_id)
+		VALUES ($1, $2)
+		ON CONFLICT (muter_id, muted_id) DO NOTHING
+	`, userID, mutedID)
+
+	if err != nil {
+		h.logger.Error("failed to mute user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.cache.InvalidateFeed(c.Request.Context(), userID)
+
+	h.logger.Info("user muted",
+		zap.String("muter_id", userID.String()),
+		zap.String("muted_id", mutedID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "muted successfully"})
+}
+
+func (h *SocialHandler) Unmute(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	mutedIDStr := c.Param("user_id")
+	mutedID, err := uuid.Parse(mutedIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	_, err = h.db.Exec(`
+		DELETE FROM mutes
+		WHERE muter_id = $1 AND muted_id = $2
+	`, userID, mutedID)
+
+	if err != nil {
+		h.logger.Error("failed to unmute user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.cache.InvalidateFeed(c.Request.Context(), userID)
+
+	h.logger.Info("user unmuted",
+		zap.String("muter_id", userID.String()),
+		zap.String("muted_id", mutedID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "unmuted successfully"})
+}
+
+func (h *SocialHandler) GetProfile(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	targetIDStr := c.Param("id")
+	targetID, err := uuid.Parse(targetIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if !h.checkUserAccess(c, userID, targetID) {
+		return
+	}
+
+	profile := models.UserProfile{UserID: targetID}
+	err = h.db.QueryRow(`
+		SELECT
+		  u.is_private,
+		  (SELECT COUNT(*) FROM follows WHERE followee_id = $1 AND status = 'accepted'),
+		  (SELECT COUNT(*) FROM follows WHERE follower_id = $1 AND status = 'accepted'),
+		  EXISTS(SELECT 1 FROM follows WHERE follower_id = $1 AND followee_id = $2 AND status = 'accepted'),
+		  EXISTS(SELECT 1 FROM follows WHERE follower_id = $2 AND followee_id = $1 AND status = 'pending')
+		FROM users u
+		WHERE u.id = $1
+	`, targetID, userID).Scan(&profile.IsPrivate, &profile.FollowersCount, &profile.FollowingCount,
+		&profile.FollowsYou, &profile.Requested)
+
+	if err != nil {
+		h.logger.Error("failed to get profile", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	followees, err := h.followees(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get followees", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	_, profile.Following = followees[targetID]
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *SocialHandler) ListFollowers(c *gin.Context) {
+	h.listFollows(c, "followee_id", "follower_id")
+}
+
+func (h *SocialHandler) ListFollowing(c *gin.Context) {
+	h.listFollows(c, "follower_id", "followee_id")
+}
+
+// listFollows pages through the follows rows where matchColumn is the target
+// user, returning the users in listColumn newest first.
+func (h *SocialHandler) listFollows(c *gin.Context, matchColumn, listColumn string) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	targetIDStr := c.Param("id")
+	targetID, err := uuid.Parse(targetIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor := pagination.Cursor{Time: time.Now().Add(time.Hour), ID: uuid.Max}
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		cursor, err = h.cursors.Decode(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	if !h.checkUserAccess(c, userID, targetID) {
+		return
+	}
+
+	if userID != targetID {
+		var hidden bool
+		err = h.db.QueryRow(`
+			SELECT u.is_private AND NOT EXISTS(
+				SELECT 1 FROM follows
+				WHERE follower_id = $2 AND followee_id = $1 AND status = 'accepted'
+			)
+			FROM users u
+			WHERE u.id = $1
+		`, targetID, userID).Scan(&hidden)
+
+		if err != nil {
+			h.logger.Error("failed to check account privacy", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if hidden {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this account is private"})
+			return
+		}
+	}
+
+	rows, err := h.db.Query(fmt.Sprintf(`
+		SELECT f.%[2]s, f.created_at,
+		  EXISTS(
+		    SELECT 1 FROM follows y
+		    WHERE y.follower_id = f.%[2]s AND y.followee_id = $2 AND y.status = 'accepted'
+		  )
+		FROM follows f
+		WHERE f.%[1]s = $1
+		  AND f.status = 'accepted'
+		  AND NOT EXISTS (
+		    SELECT 1 FROM blocks b
+		    WHERE (b.blocker_id = f.%[2]s AND b.blocked_id = $2)
+		       OR (b.blocker_id = $2 AND b.blocked_id = f.%[2]s)
+		  )
+		  AND (f.created_at, f.%[2]s) < ($3, $4)
+		ORDER BY f.created_at DESC, f.%[2]s DESC
+		LIMIT $5
+	`, matchColumn, listColumn), targetID, userID, cursor.Time, cursor.ID, limit+1)
+
+	if err != nil {
+		h.logger.Error("failed to list follows", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	followees, err := h.followees(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get followees", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	users := []models.FollowUser{}
+	for rows.Next() {
+		var user models.FollowUser
+		if err := rows.Scan(&user.UserID, &user.FollowedAt, &user.FollowsYou); err != nil {
+			h.logger.Error("failed to scan follow", zap.Error(err))
+			continue
+		}
+		_, user.Following = followees[user.UserID]
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate follows", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := models.FollowListResponse{Users: users}
+	if len(users) > limit {
+		resp.Users = users[:limit]
+		last := resp.Users[limit-1]
+		next := h.cursors.Encode(pagination.Cursor{Time: last.FollowedAt, ID: last.UserID})
+		resp.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// checkUserAccess verifies the target user exists and has not blocked (or
+// been blocked by) the caller. On failure it writes the error response.
+func (h *SocialHandler) checkUserAccess(c *gin.Context, userID, targetID uuid.UUID) bool {
+	var exists, blocked bool
+	err := h.db.QueryRow(`
+		SELECT
+		  EXISTS(SELECT 1 FROM users WHERE id = $2),
+		  EXISTS(
+		    SELECT 1 FROM blocks
+		    WHERE (blocker_id = $1 AND blocked_id = $2)
+		       OR (blocker_id = $2 AND blocked_id = $1)
+		  )
+	`, userID, targetID).Scan(&exists, &blocked)
+
+	if err != nil {
+		h.logger.Error("failed to check user access", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return false
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return false
+	}
+
+	return true
+}
+
+// followees returns the set of users the given user follows, served from the
+// cache when possible.
+func (h *SocialHandler) followees(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	ids, err := h.cache.GetFollowees(ctx, userID)
+	if err != nil {
+		rows, err := h.db.QueryContext(ctx, `
+			SELECT followee_id FROM follows
+			WHERE follower_id = $1 AND status = 'accepted'
+		`, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		ids = []uuid.UUID{}
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		h.cache.SetFollowees(ctx, userID, ids)
+	}
+
+	set := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set, nil
+}
+
+func (h *SocialHandler) SetPrivacy(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.PrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET is_private = $2 WHERE id = $1", userID, *req.Private); err != nil {
+		h.logger.Error("failed to update privacy", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// Going public accepts every pending request.
+	var accepted []uuid.UUID
+	if !*req.Private {
+		rows, err := tx.Query(`
+			UPDATE follows SET status = 'accepted'
+			WHERE followee_id = $1 AND status = 'pending'
+			RETURNING follower_id
+		`, userID)
+		if err != nil {
+			h.logger.Error("failed to accept pending follows", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		for rows.Next() {
+			var followerID uuid.UUID
+			if err := rows.Scan(&followerID); err != nil {
+				rows.Close()
+				h.logger.Error("failed to scan accepted follow", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			accepted = append(accepted, followerID)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			h.logger.Error("failed to accept pending follows", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		h.logger.Error("failed to commit transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// Followers' cached pages may hold stories the account no longer shows
+	// them, or lack ones it now does.
+	invalidateFollowerFeeds(c.Request.Context(), h.db, h.cache, h.logger, userID)
+	for _, followerID := range accepted {
+		h.notifyFollowAccepted(c.Request.Context(), followerID, userID)
+	}
+	if *req.Private {
+		h.recheckAuthorTopics(c.Request.Context(), userID)
+	}
+
+	h.logger.Info("privacy updated",
+		zap.String("user_id", userID.String()),
+		zap.Bool("private", *req.Private))
+
+	c.JSON(http.StatusOK, gin.H{"private": *req.Private})
+}
+
+func (h *SocialHandler) ListFollowRequests(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor := pagination.Cursor{Time: time.Now().Add(time.Hour), ID: uuid.Max}
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		cursor, err = h.cursors.Decode(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	rows, err := h.db.Query(`
+		SELECT f.follower_id, f.created_at
+		FROM follows f
+		WHERE f.followee_id = $1
+		  AND f.status = 'pending'
+		  AND (f.created_at, f.follower_id) < ($2, $3)
+		ORDER BY f.created_at DESC, f.follower_id DESC
+		LIMIT $4
+	`, userID, cursor.Time, cursor.ID, limit+1)
+
+	if err != nil {
+		h.logger.Error("failed to list follow requests", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	users := []models.FollowUser{}
+	for rows.Next() {
+		var user models.FollowUser
+		if err := rows.Scan(&user.UserID, &user.FollowedAt); err != nil {
+			h.logger.Error("failed to scan follow request", zap.Error(err))
+			continue
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate follow requests", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := models.FollowListResponse{Users: users}
+	if len(users) > limit {
+		resp.Users = users[:limit]
+		last := resp.Users[limit-1]
+		next := h.cursors.Encode(pagination.Cursor{Time: last.FollowedAt, ID: last.UserID})
+		resp.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// recheckAuthorTopics drops subscriptions to an author's topic and to their
+// active stories held by users who may no longer see them.
+func (h *SocialHandler) recheckAuthorTopics(ctx context.Context, authorID uuid.UUID) {
+	h.hub.RecheckTopic(websocket.AuthorTopic(authorID))
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id FROM stories
+		WHERE author_id = $1 AND deleted_at IS NULL AND expires_at > NOW()
+	`, authorID)
+	if err != nil {
+		h.logger.Error("failed to list active stories", zap.Error(err))
+		return
+	}
+	// Stories listed before a failure are still rechecked.
+	var storyIDs []uuid.UUID
+	for rows.Next() {
+		var storyID uuid.UUID
+		if err := rows.Scan(&storyID); err != nil {
+			h.logger.Error("failed to scan active story", zap.Error(err))
+			continue
+		}
+		storyIDs = append(storyIDs, storyID)
+	}
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate active stories", zap.Error(err))
+	}
+	rows.Close()
+
+	for _, storyID := range storyIDs {
+		h.hub.RecheckTopic(websocket.StoryTopic(storyID))
+	}
+}
+
+func (h *SocialHandler) ApproveFollowRequest(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	followerIDStr := c.Param("user_id")
+	followerID, err := uuid.Parse(followerIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE follows SET status = 'accepted'
+		WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+	`, followerID, userID)
+
+	if err != nil {
+		h.logger.Error("failed to approve follow request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "follow request not found"})
+		return
+	}
+
+	h.notifyFollowAccepted(c.Request.Context(), followerID, userID)
+
+	h.logger.Info("follow request approved",
+		zap.String("follower_id", followerID.String()),
+		zap.String("followee_id", userID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "follow request approved"})
+}
+
+func (h *SocialHandler) RejectFollowRequest(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	followerIDStr := c.Param("user_id")
+	followerID, err := uuid.Parse(followerIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	result, err := h.db.Exec(`
+		DELETE FROM follows
+		WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+	`, followerID, userID)
+
+	if err != nil {
+		h.logger.Error("failed to reject follow request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "follow request not found"})
+		return
+	}
+
+	h.logger.Info("follow request rejected",
+		zap.String("follower_id", followerID.String()),
+		zap.String("followee_id", userID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "follow request rejected"})
+}
+
+func (h *SocialHandler) notifyFollowAccepted(ctx context.Context, followerID, followeeID uuid.UUID) {
+	h.backfillTimeline(ctx, followerID, followeeID)
+	h.cache.InvalidateFeed(ctx, followerID)
+	h.cache.InvalidateFollowees(ctx, followerID)
+
+	h.hub.SendToUser(followerID, websocket.Event{
+		Type: "follow.accepted",
+		Payload: websocket.FollowEvent{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+		},
+	})
+}
+
+// backfillTimeline copies the followee's active stories onto a new
+// follower's timeline, since the feed no longer pulls fanned-out ones from
+// Postgres once the follow is accepted. Stories not yet marked fanned_out are
+// copied too: fanOut may have read the followers before this follow was
+// accepted and still be pushing, and once it marks the story the pull would
+// skip it.
+func (h *SocialHandler) backfillTimeline(ctx context.Context, followerID, followeeID uuid.UUID) {
+	if h.cache == nil {
+		return
+	}
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, created_at FROM stories
+		WHERE author_id = $1 AND deleted_at IS NULL AND expires_at > NOW()
+	`, followeeID)
+	if err != nil {
+		h.logger.Error("failed to get stories for timeline backfill", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+
+	var entries []cache.TimelineEntry
+	for rows.Next() {
+		var entry cache.TimelineEntry
+		if err := rows.Scan(&entry.StoryID, &entry.CreatedAt); err != nil {
+			h.logger.Error("failed to scan story", zap.Error(err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := h.cache.BackfillTimeline(ctx, followerID, entries); err != nil {
+		h.logger.Error("failed to backfill timeline", zap.Error(err))
+	}
+}
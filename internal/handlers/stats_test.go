@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatsWindow(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name            string
+		from, to, gran  string
+		wantFrom        string
+		wantTo          string
+		wantGranularity string
+		wantErr         bool
+	}{
+		{
+			name: "aligned bounds kept", from: "2025-01-01T00:00:00Z", to: "2025-01-08T00:00:00Z",
+			wantFrom: "2025-01-01T00:00:00Z", wantTo: "2025-01-08T00:00:00Z", wantGranularity: "day",
+		},
+		{
+			name: "widened to whole hours", from: "2025-01-01T10:20:00Z", to: "2025-01-01T12:05:00Z", gran: "hour",
+			wantFrom: "2025-01-01T10:00:00Z", wantTo: "2025-01-01T13:00:00Z", wantGranularity: "hour",
+		},
+		{
+			name: "offset bounds aligned in UTC", from: "2025-01-01T10:20:00+02:00", to: "2025-01-01T12:00:00+02:00", gran: "hour",
+			wantFrom: "2025-01-01T08:00:00Z", wantTo: "2025-01-01T10:00:00Z", wantGranularity: "hour",
+		},
+		{
+			name: "missing from defaults to a week", to: "2025-01-08T06:30:00Z",
+			wantFrom: "2025-01-01T06:00:00Z", wantTo: "2025-01-08T07:00:00Z", wantGranularity: "day",
+		},
+		{
+			name: "day range at cap", from: "2024-01-01T00:00:00Z", to: "2025-01-01T00:00:00Z", gran: "day",
+			wantFrom: "2024-01-01T00:00:00Z", wantTo: "2025-01-01T00:00:00Z", wantGranularity: "day",
+		},
+		{
+			name: "hour range at cap", from: "2025-01-01T00:00:00Z", to: "2025-01-08T00:00:00Z", gran: "hour",
+			wantFrom: "2025-01-01T00:00:00Z", wantTo: "2025-01-08T00:00:00Z", wantGranularity: "hour",
+		},
+		{name: "day range over cap", from: "2023-01-01T00:00:00Z", to: "2025-01-01T00:00:00Z", wantErr: true},
+		{name: "hour range over cap", from: "2025-01-01T00:00:00Z", to: "2025-01-08T00:00:01Z", gran: "hour", wantErr: true},
+		{name: "from after to", from: "2025-01-02T00:00:00Z", to: "2025-01-01T00:00:00Z", wantErr: true},
+		{name: "from equals to", from: "2025-01-01T00:00:00Z", to: "2025-01-01T00:00:00Z", wantErr: true},
+		{name: "from after default to", from: "2999-01-01T00:00:00Z", wantErr: true},
+		{name: "invalid from", from: "yesterday", to: "2025-01-01T00:00:00Z", wantErr: true},
+		{name: "invalid to", to: "2025-01-01", wantErr: true},
+		{name: "invalid granularity", from: "2025-01-01T00:00:00Z", to: "2025-01-02T00:00:00Z", gran: "week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := parseStatsWindow(tt.from, tt.to, tt.gran)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatsWindow error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !window.From.Equal(at(tt.wantFrom)) || !window.To.Equal(at(tt.wantTo)) {
+				t.Errorf("window = %s..%s, want %s..%s", window.From.Format(time.RFC3339),
+					window.To.Format(time.RFC3339), tt.wantFrom, tt.wantTo)
+			}
+			if window.Granularity != tt.wantGranularity {
+				t.Errorf("granularity = %q, want %q", window.Granularity, tt.wantGranularity)
+			}
+		})
+	}
+}
+
+// TestParseStatsWindowDefaults checks the window ending now when no bounds
+// are given.
+func TestParseStatsWindowDefaults(t *testing.T) {
+	before := time.Now()
+	window, err := parseStatsWindow("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if window.Granularity != "day" {
+		t.Errorf("granularity = %q, want day", window.Granularity)
+	}
+	if !window.From.Equal(window.From.Truncate(time.Hour)) || !window.To.Equal(window.To.Truncate(time.Hour)) {
+		t.Errorf("window %s..%s is not aligned to hours", window.From, window.To)
+	}
+	if window.To.Before(before) || window.To.Sub(before) > time.Hour {
+		t.Errorf("to = %s, want the hour after %s", window.To, before)
+	}
+	if span := window.To.Sub(window.From); span < 7*24*time.Hour || span > 7*24*time.Hour+time.Hour {
+		t.Errorf("window spans %s, want about 7 days", span)
+	}
+}
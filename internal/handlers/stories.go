@@ -1,42 +1,97 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"time"
 
 	"stories-service/internal/cache"
+	"stories-service/internal/config"
 	"stories-service/internal/db"
 	"stories-service/internal/metrics"
 	"stories-service/internal/middleware"
 	"stories-service/internal/models"
+	"stories-service/internal/pagination"
+	"stories-service/internal/reactions"
 	"stories-service/internal/storage"
+	"stories-service/internal/visibility"
 	"stories-service/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 type StoriesHandler struct {
-	db      *db.DB
-	storage *storage.Storage
-	cache   *cache.Cache
-	hub     *websocket.Hub
-	logger  *zap.Logger
+	db        *db.DB
+	storage   *storage.Storage
+	cache     *cache.Cache
+	hub       *websocket.Hub
+	cursors   *pagination.Codec
+	access    *visibility.Evaluator
+	lifetime  config.StoryLifetime
+	fanout    config.FeedFanout
+	reactions *reactions.Catalogue
+	logger    *zap.Logger
 }
 
-func NewStoriesHandler(database *db.DB, stor *storage.Storage, cach *cache.Cache, hub *websocket.Hub, logger *zap.Logger) *StoriesHandler {
+func NewStoriesHandler(database *db.DB, stor *storage.Storage, cach *cache.Cache, hub *websocket.Hub, cursors *pagination.Codec, lifetime config.StoryLifetime, fanout config.FeedFanout, catalogue *reactions.Catalogue, logger *zap.Logger) *StoriesHandler {
 	return &StoriesHandler{
-		db:      database,
-		storage: stor,
-		cache:   cach,
-		hub:     hub,
-		logger:  logger,
+		db:        database,
+		storage:   stor,
+		cache:     cach,
+		hub:       hub,
+		cursors:   cursors,
+		access:    visibility.NewEvaluator(database),
+		lifetime:  lifetime,
+		fanout:    fanout,
+		reactions: catalogue,
+		logger:    logger,
 	}
 }
 
+// loadVisibleStory fetches an active story and checks that the viewer may see
+// it. On failure it writes the error response and returns false.
+func (h *StoriesHandler) loadVisibleStory(c *gin.Context, viewerID, storyID uuid.UUID) (models.Story, bool) {
+	var story models.Story
+	err := h.db.QueryRow(`
+		SELECT id, author_id, text, media_key, visibility, created_at, expires_at, edited_at, deleted_at
+		FROM stories
+		WHERE id = $1 AND deleted_at IS NULL AND expires_at > NOW()
+	`, storyID).Scan(&story.ID, &story.AuthorID, &story.Text, &story.MediaKey,
+		&story.Visibility, &story.CreatedAt, &story.ExpiresAt, &story.EditedAt, &story.DeletedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		return story, false
+	}
+	if err != nil {
+		h.logger.Error("failed to get story", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return story, false
+	}
+
+	canView, err := h.access.CanView(c.Request.Context(), viewerID, storyID)
+	if err != nil {
+		h.logger.Error("failed to check story visibility", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return story, false
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return story, false
+	}
+
+	return story, true
+}
+
 func (h *StoriesHandler) CreateStory(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
@@ -63,6 +118,20 @@ func (h *StoriesHandler) CreateStory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "text or media_key required"})
 		return
 	}
+	if req.Visibility != visibility.Friends && len(req.AudienceUserIDs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "audience_user_ids requires friends visibility"})
+		return
+	}
+
+	lifetime := h.lifetime.Default
+	if req.Duration != nil {
+		lifetime = time.Duration(*req.Duration) * time.Second
+		if lifetime < h.lifetime.Min || lifetime > h.lifetime.Max {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("duration must be between %d and %d seconds",
+				int(h.lifetime.Min.Seconds()), int(h.lifetime.Max.Seconds()))})
+			return
+		}
+	}
 
 	tx, err := h.db.Begin()
 	if err != nil {
@@ -73,13 +142,14 @@ func (h *StoriesHandler) CreateStory(c *gin.Context) {
 	defer tx.Rollback()
 
 	var storyID uuid.UUID
-	expiresAt := time.Now().Add(24 * time.Hour)
+	var createdAt time.Time
+	expiresAt := time.Now().Add(lifetime)
 
 	err = tx.QueryRow(`
 		INSERT INTO stories (author_id, text, media_key, visibility, expires_at)
 		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, userID, req.Text, req.MediaKey, req.Visibility, expiresAt).Scan(&storyID)
+		RETURNING id, created_at
+	`, userID, req.Text, req.MediaKey, req.Visibility, expiresAt).Scan(&storyID, &createdAt)
 
 	if err != nil {
 		h.logger.Error("failed to create story", zap.Error(err))
@@ -87,7 +157,7 @@ func (h *StoriesHandler) CreateStory(c *gin.Context) {
 		return
 	}
 
-	if req.Visibility == "friends" && len(req.AudienceUserIDs) > 0 {
+	if req.Visibility == visibility.Friends && len(req.AudienceUserIDs) > 0 {
 		for _, audienceUserID := range req.AudienceUserIDs {
 			_, err = tx.Exec(`
 				INSERT INTO story_audience (story_id, user_id) VALUES ($1, $2)
@@ -118,10 +188,25 @@ func (h *StoriesHandler) CreateStory(c *gin.Context) {
 		Text:       req.Text,
 		MediaKey:   req.MediaKey,
 		Visibility: req.Visibility,
-		CreatedAt:  time.Now(),
+		CreatedAt:  createdAt,
 		ExpiresAt:  expiresAt,
 	}
 
+	h.fanOut(c.Request.Context(), story)
+
+	// Author topics are open to anyone who can see the author's public
+	// stories, so only those are announced there.
+	if story.Visibility == visibility.Public {
+		h.hub.SendToTopic(websocket.AuthorTopic(userID), websocket.Event{
+			Type: "story.created",
+			Payload: websocket.StoryCreatedEvent{
+				StoryID:   storyID,
+				AuthorID:  userID,
+				CreatedAt: createdAt.Format(time.RFC3339),
+			},
+		})
+	}
+
 	c.JSON(http.StatusCreated, story)
 }
 
@@ -139,102 +224,136 @@ func (h *StoriesHandler) GetStory(c *gin.Context) {
 		return
 	}
 
-	var story models.Story
-	err = h.db.QueryRow(`
-		SELECT id, author_id, text, media_key, visibility, created_at, expires_at, deleted_at
-		FROM stories
-		WHERE id = $1 AND deleted_at IS NULL
-	`, storyID).Scan(&story.ID, &story.AuthorID, &story.Text, &story.MediaKey,
-		&story.Visibility, &story.CreatedAt, &story.ExpiresAt, &story.DeletedAt)
+	story, ok := h.loadVisibleStory(c, userID, storyID)
+	if !ok {
+		return
+	}
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+	c.JSON(http.StatusOK, story)
+}
+
+func (h *StoriesHandler) UpdateStory(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
 	if err != nil {
-		h.logger.Error("failed to get story", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
 		return
 	}
 
-	canView := false
-	if story.AuthorID == userID {
-		canView = true
-	} else if story.Visibility == "public" {
-		canView = true
-	} else if story.Visibility == "friends" {
-		var isFollowing bool
-		err = h.db.QueryRow(`
-			SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id = $1 AND followee_id = $2)
-		`, userID, story.AuthorID).Scan(&isFollowing)
-		if err == nil && isFollowing {
-			canView = true
-		}
+	var req models.UpdateStoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if !canView {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	story, ok := h.loadOwnedStory(c, userID, storyID)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, story)
-}
+	if req.Text != nil {
+		story.Text = req.Text
+		if *req.Text == "" {
+			story.Text = nil
+		}
+	}
+	if story.Text == nil && story.MediaKey == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text or media_key required"})
+		return
+	}
 
-func (h *StoriesHandler) GetFeed(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	visibilityChanged := req.Visibility != nil && *req.Visibility != story.Visibility
+	if req.Visibility != nil {
+		story.Visibility = *req.Visibility
+	}
+	if story.Visibility != visibility.Friends && req.AudienceUserIDs != nil && len(*req.AudienceUserIDs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "audience_user_ids requires friends visibility"})
 		return
 	}
 
-	cacheKey := fmt.Sprintf("feed:%s", userID.String())
-	var stories []models.Story
-	err := h.cache.Get(c.Request.Context(), cacheKey, &stories)
-	if err == nil {
-		c.JSON(http.StatusOK, gin.H{"stories": stories, "cached": true})
+	tx, err := h.db.Begin()
+	if err != nil {
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rows, err := h.db.Query(`
-		SELECT DISTINCT s.id, s.author_id, s.text, s.media_key, s.visibility, s.created_at, s.expires_at
-		FROM stories s
-		LEFT JOIN follows f ON s.author_id = f.followee_id AND f.follower_id = $1
-		WHERE s.deleted_at IS NULL
-		  AND s.expires_at > NOW()
-		  AND (
-		    s.visibility = 'public'
-		    OR (s.visibility = 'friends' AND f.follower_id IS NOT NULL)
-		    OR s.author_id = $1
-		  )
-		ORDER BY s.created_at DESC
-		LIMIT 50
-	`, userID)
+	err = tx.QueryRow(`
+		UPDATE stories
+		SET text = $2, visibility = $3, edited_at = NOW()
+		WHERE id = $1
+		RETURNING edited_at
+	`, storyID, story.Text, story.Visibility).Scan(&story.EditedAt)
 
 	if err != nil {
-		h.logger.Error("failed to get feed", zap.Error(err))
+		h.logger.Error("failed to update story", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
-	defer rows.Close()
 
-	stories = []models.Story{}
-	for rows.Next() {
-		var story models.Story
-		err := rows.Scan(&story.ID, &story.AuthorID, &story.Text, &story.MediaKey,
-			&story.Visibility, &story.CreatedAt, &story.ExpiresAt)
+	if req.AudienceUserIDs != nil || visibilityChanged {
+		_, err = tx.Exec("DELETE FROM story_audience WHERE story_id = $1", storyID)
 		if err != nil {
-			h.logger.Error("failed to scan story", zap.Error(err))
-			continue
+			h.logger.Error("failed to clear audience", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
 		}
-		stories = append(stories, story)
 	}
 
-	h.cache.Set(c.Request.Context(), cacheKey, stories, 30*time.Second)
+	if story.Visibility == visibility.Friends && req.AudienceUserIDs != nil {
+		for _, audienceUserID := range *req.AudienceUserIDs {
+			_, err = tx.Exec(`
+				INSERT INTO story_audience (story_id, user_id) VALUES ($1, $2)
+				ON CONFLICT DO NOTHING
+			`, storyID, audienceUserID)
+			if err != nil {
+				h.logger.Error("failed to insert audience", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		h.logger.Error("failed to commit transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.invalidateFeeds(c.Request.Context(), userID)
+
+	editedAt := time.Now()
+	if story.EditedAt != nil {
+		editedAt = *story.EditedAt
+	}
+	h.hub.SendToTopic(websocket.StoryTopic(storyID), websocket.Event{
+		Type: "story.updated",
+		Payload: websocket.StoryUpdatedEvent{
+			StoryID:  storyID,
+			AuthorID: userID,
+			EditedAt: editedAt.Format(time.RFC3339),
+		},
+	})
+	if req.AudienceUserIDs != nil || visibilityChanged {
+		h.hub.RecheckTopic(websocket.StoryTopic(storyID))
+	}
+
+	h.logger.Info("story updated",
+		zap.String("story_id", storyID.String()),
+		zap.String("author_id", userID.String()),
+		zap.String("visibility", story.Visibility))
 
-	c.JSON(http.StatusOK, gin.H{"stories": stories})
+	c.JSON(http.StatusOK, story)
 }
 
-func (h *StoriesHandler) ViewStory(c *gin.Context) {
+func (h *StoriesHandler) DeleteStory(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -248,161 +367,1184 @@ func (h *StoriesHandler) ViewStory(c *gin.Context) {
 		return
 	}
 
-	var authorID uuid.UUID
-	err = h.db.QueryRow("SELECT author_id FROM stories WHERE id = $1", storyID).Scan(&authorID)
+	if _, ok := h.loadOwnedStory(c, userID, storyID); !ok {
+		return
+	}
+
+	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		h.logger.Error("failed to begin transaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	defer tx.Rollback()
 
-	_, err = h.db.Exec(`
-		INSERT INTO story_views (story_id, viewer_id, viewed_at)
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (story_id, viewer_id) DO NOTHING
-	`, storyID, userID)
+	_, err = tx.Exec(`
+		UPDATE stories SET deleted_at = NOW(), taken_down_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, storyID)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM highlight_stories WHERE story_id = $1", storyID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
-		h.logger.Error("failed to record view", zap.Error(err))
+		h.logger.Error("failed to delete story", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
-	metrics.StoryViewsTotal.Inc()
+	h.invalidateFeeds(c.Request.Context(), userID)
 
-	h.hub.SendToUser(authorID, websocket.Event{
-		Type: "story.viewed",
-		Payload: websocket.ViewEvent{
+	// Viewers with the story open subscribe to its topic or watch it;
+	// nobody else needs to hear about it.
+	deleted := websocket.Event{
+		Type: "story.deleted",
+		Payload: websocket.StoryDeletedEvent{
 			StoryID:  storyID,
-			ViewerID: userID,
-			ViewedAt: time.Now().Format(time.RFC3339),
+			AuthorID: userID,
 		},
-	})
+	}
+	h.hub.SendToTopic(websocket.StoryTopic(storyID), deleted)
+	if h.cache != nil {
+		watchers, err := h.cache.ClearStoryWatchers(c.Request.Context(), storyID)
+		if err != nil {
+			h.logger.Error("failed to clear story watchers", zap.Error(err))
+		}
+		for _, watcherID := range watchers {
+			h.hub.SendEphemeral(watcherID, deleted)
+		}
+	}
 
-	h.logger.Info("story viewed",
+	h.logger.Info("story deleted",
 		zap.String("story_id", storyID.String()),
-		zap.String("viewer_id", userID.String()))
+		zap.String("author_id", userID.String()))
 
-	c.JSON(http.StatusOK, gin.H{"message": "view recorded"})
+	c.JSON(http.StatusOK, gin.H{"message": "story deleted"})
 }
 
-func (h *StoriesHandler) AddReaction(c *gin.Context) {
+// loadOwnedStory fetches a story that has not been deleted or expired and
+// checks that the caller wrote it. On failure it writes the error response and returns false.
+func (h *StoriesHandler) loadOwnedStory(c *gin.Context, userID, storyID uuid.UUID) (models.Story, bool) {
+	var story models.Story
+	err := h.db.QueryRow(`
+		SELECT id, author_id, text, media_key, visibility, created_at, expires_at, edited_at
+		FROM stories
+		WHERE id = $1 AND deleted_at IS NULL AND expires_at > NOW()
+	`, storyID).Scan(&story.ID, &story.AuthorID, &story.Text, &story.MediaKey,
+		&story.Visibility, &story.CreatedAt, &story.ExpiresAt, &story.EditedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		return story, false
+	}
+	if err != nil {
+		h.logger.Error("failed to get story", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return story, false
+	}
+
+	if story.AuthorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return story, false
+	}
+
+	return story, true
+}
+
+// invalidateFeeds drops cached feed pages for an author and their followers
+// after one of the author's stories changes.
+func (h *StoriesHandler) invalidateFeeds(ctx context.Context, authorID uuid.UUID) {
+	invalidateFollowerFeeds(ctx, h.db, h.cache, h.logger, authorID)
+}
+
+// invalidateFollowerFeeds drops cached feed pages for an author and their
+// accepted followers.
+func invalidateFollowerFeeds(ctx context.Context, database *db.DB, feedCache *cache.Cache, logger *zap.Logger, authorID uuid.UUID) {
+	if feedCache == nil {
+		return
+	}
+
+	userIDs := []uuid.UUID{authorID}
+	rows, err := database.QueryContext(ctx, `
+		SELECT follower_id FROM follows
+		WHERE followee_id = $1 AND status = 'accepted'
+	`, authorID)
+	if err != nil {
+		logger.Error("failed to get followers", zap.Error(err))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var followerID uuid.UUID
+		if err := rows.Scan(&followerID); err != nil {
+			logger.Error("failed to scan follower", zap.Error(err))
+			continue
+		}
+		userIDs = append(userIDs, followerID)
+	}
+
+	if err := feedCache.InvalidateFeed(ctx, userIDs...); err != nil {
+		logger.Error("failed to invalidate feeds", zap.Error(err))
+	}
+}
+
+func (h *StoriesHandler) GetFeed(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	allowed, err := h.cache.CheckRateLimit(c.Request.Context(), userID, "react", 60, time.Minute)
+	limit, err := pagination.ParseLimit(c.Query("limit"))
 	if err != nil {
-		h.logger.Error("rate limit check failed", zap.Error(err))
-	}
-	if !allowed {
-		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	storyIDStr := c.Param("id")
-	storyID, err := uuid.Parse(storyIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+	rawCursor := c.Query("cursor")
+	cursor := pagination.Cursor{Time: time.Now().Add(time.Hour), ID: uuid.Max}
+	if rawCursor != "" {
+		cursor, err = h.cursors.Decode(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	switch c.Query("mode") {
+	case "", "flat":
+	case "grouped":
+		h.getGroupedFeed(c, userID, cursor, limit)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be flat or grouped"})
 		return
 	}
 
-	var req models.ReactRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var feed models.FeedResponse
+	if err := h.cache.GetFeedPage(c.Request.Context(), userID, rawCursor, limit, &feed); err == nil {
+		feed.Cached = true
+		c.JSON(http.StatusOK, feed)
 		return
 	}
 
-	var authorID uuid.UUID
-	err = h.db.QueryRow("SELECT author_id FROM stories WHERE id = $1", storyID).Scan(&authorID)
+	stories, err := h.feedStories(c.Request.Context(), userID, cursor, limit+1)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		h.logger.Error("failed to get feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
 
-	var reactionID uuid.UUID
-	err = h.db.QueryRow(`
-		INSERT INTO reactions (story_id, user_id, emoji)
-		VALUES ($1, $2, $3)
-		RETURNING id
-	`, storyID, userID, req.Emoji).Scan(&reactionID)
+	feed = models.FeedResponse{Stories: stories}
+	if len(stories) > limit {
+		feed.Stories = stories[:limit]
+		last := feed.Stories[limit-1]
+		next := h.cursors.Encode(pagination.Cursor{Time: last.CreatedAt, ID: last.ID})
+		feed.NextCursor = &next
+	}
+
+	h.cache.SetFeedPage(c.Request.Context(), userID, rawCursor, limit, feed)
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// getGroupedFeed serves GET /feed?mode=grouped: active visible stories grouped
+// per author, each marked seen if the caller has viewed it. Authors with
+// unseen stories come first, ordered by their newest unseen story, followed
+// by fully seen authors ordered by their newest story. The limit and cursor
+// apply to authors; each author's stories are returned oldest first, in
+// playback order. Seen state changes with every view, so these pages are
+// not cached.
+func (h *StoriesHandler) getGroupedFeed(c *gin.Context, userID uuid.UUID, cursor pagination.Cursor, limit int) {
+	// Fully seen authors are shifted back a century so both groups share a
+	// single (sort_key, author_id) keyset; active stories never span that far.
+	rows, err := h.db.Query(`
+		WITH visible AS (
+			SELECT s.id, s.author_id, s.text, s.media_key, s.visibility, s.created_at, s.expires_at, s.edited_at,
+				EXISTS (
+					SELECT 1 FROM story_views sv WHERE sv.story_id = s.id AND sv.viewer_id = $1
+				) AS seen
+			FROM stories s
+			WHERE s.deleted_at IS NULL
+			  AND s.expires_at > NOW()
+			  AND `+visibility.FeedPredicate("s", "$1")+`
+		),
+		authors AS (
+			SELECT author_id,
+				bool_or(NOT seen) AS has_unseen,
+				MAX(created_at) AS latest_at,
+				COALESCE(
+					MAX(created_at) FILTER (WHERE NOT seen),
+					MAX(created_at) - INTERVAL '100 years'
+				) AS sort_key
+			FROM visible
+			GROUP BY author_id
+		),
+		page AS (
+			SELECT * FROM authors
+			WHERE (sort_key, author_id) < ($2, $3)
+			ORDER BY sort_key DESC, author_id DESC
+			LIMIT $4
+		)
+		SELECT p.author_id, p.has_unseen, p.latest_at, p.sort_key,
+			v.id, v.text, v.media_key, v.visibility, v.created_at, v.expires_at, v.edited_at, v.seen
+		FROM page p
+		JOIN visible v ON v.author_id = p.author_id
+		ORDER BY p.sort_key DESC, p.author_id DESC, v.created_at ASC, v.id ASC
+	`, userID, cursor.Time, cursor.ID, limit+1)
 
 	if err != nil {
-		h.logger.Error("failed to add reaction", zap.Error(err))
+		h.logger.Error("failed to get grouped feed", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	defer rows.Close()
 
-	metrics.ReactionsTotal.Inc()
+	authors := []models.AuthorStories{}
+	var sortKeys []time.Time
+	for rows.Next() {
+		var group models.AuthorStories
+		var sortKey time.Time
+		var story models.FeedStory
+		err := rows.Scan(&group.AuthorID, &group.HasUnseen, &group.LatestAt, &sortKey,
+			&story.ID, &story.Text, &story.MediaKey, &story.Visibility,
+			&story.CreatedAt, &story.ExpiresAt, &story.EditedAt, &story.Seen)
+		if err != nil {
+			h.logger.Error("failed to scan story", zap.Error(err))
+			continue
+		}
+		story.AuthorID = group.AuthorID
 
-	h.hub.SendToUser(authorID, websocket.Event{
-		Type: "story.reacted",
-		Payload: websocket.ReactionEvent{
-			StoryID: storyID,
-			UserID:  userID,
-			Emoji:   req.Emoji,
-		},
-	})
+		if n := len(authors); n == 0 || authors[n-1].AuthorID != group.AuthorID {
+			authors = append(authors, group)
+			sortKeys = append(sortKeys, sortKey)
+		}
+		last := &authors[len(authors)-1]
+		last.Stories = append(last.Stories, story)
+	}
 
-	h.logger.Info("reaction added",
-		zap.String("story_id", storyID.String()),
-		zap.String("user_id", userID.String()),
-		zap.String("emoji", req.Emoji))
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate grouped feed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": reactionID, "emoji": req.Emoji})
+	feed := models.GroupedFeedResponse{Authors: authors}
+	if len(authors) > limit {
+		feed.Authors = authors[:limit]
+		next := h.cursors.Encode(pagination.Cursor{Time: sortKeys[limit-1], ID: authors[limit-1].AuthorID})
+		feed.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, feed)
 }
 
-func (h *StoriesHandler) GetStats(c *gin.Context) {
-	userID, ok := middleware.GetUserID(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+// fanOut pushes a new story onto the Redis timelines of its author and their
+// accepted followers and marks it fanned_out. Authors with more followers
+// than the fan-out limit are skipped, and their stories are pulled into
+// feeds at read time instead.
+func (h *StoriesHandler) fanOut(ctx context.Context, story models.Story) {
+	if h.cache == nil {
 		return
 	}
 
-	var posted int
-	h.db.QueryRow(`
-		SELECT COUNT(*) FROM stories 
-		WHERE author_id = $1 AND created_at > NOW() - INTERVAL '7 days'
-	`, userID).Scan(&posted)
+	var followerCount int
+	err := h.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM follows
+		WHERE followee_id = $1 AND status = 'accepted'
+	`, story.AuthorID).Scan(&followerCount)
+	if err != nil {
+		h.logger.Error("failed to count followers", zap.Error(err))
+		return
+	}
 
-	var views int
-	h.db.QueryRow(`
-		SELECT COUNT(*) FROM story_views sv
-		JOIN stories s ON sv.story_id = s.id
-		WHERE s.author_id = $1 AND sv.viewed_at > NOW() - INTERVAL '7 days'
-	`, userID).Scan(&views)
+	if followerCount > h.fanout.MaxFollowers {
+		if err := h.cache.InvalidateFeed(ctx, story.AuthorID); err != nil {
+			h.logger.Error("failed to invalidate feeds", zap.Error(err))
+		}
+		return
+	}
 
-	var uniqueViewers int
-	h.db.QueryRow(`
-		SELECT COUNT(DISTINCT sv.viewer_id) FROM story_views sv
-		JOIN stories s ON sv.story_id = s.id
-		WHERE s.author_id = $1 AND sv.viewed_at > NOW() - INTERVAL '7 days'
-	`, userID).Scan(&uniqueViewers)
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT follower_id FROM follows
+		WHERE followee_id = $1 AND status = 'accepted'
+	`, story.AuthorID)
+	if err != nil {
+		h.logger.Error("failed to get followers", zap.Error(err))
+		return
+	}
+	defer rows.Close()
 
-	rows, err := h.db.Query(`
-		SELECT r.emoji, COUNT(*) as count
-		FROM reactions r
-		JOIN stories s ON r.story_id = s.id
-		WHERE s.author_id = $1 AND r.created_at > NOW() - INTERVAL '7 days'
-		GROUP BY r.emoji
-	`, userID)
-
-	reactions := make(map[string]int)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var emoji string
-			var count int
-			rows.Scan(&emoji, &count)
-			reactions[emoji] = count
+	recipients := []uuid.UUID{story.AuthorID}
+	for rows.Next() {
+		var followerID uuid.UUID
+		if err := rows.Scan(&followerID); err != nil {
+			h.logger.Error("failed to scan follower", zap.Error(err))
+			return
 		}
+		recipients = append(recipients, followerID)
 	}
-
-	c.JSON(http.StatusOK, models.StatsResponse{
-		Posted:        posted,
-		Views:         views,
-		UniqueViewers: uniqueViewers,
-		Reactions:     reactions,
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to get followers", zap.Error(err))
+		return
+	}
+
+	entry := cache.TimelineEntry{StoryID: story.ID, CreatedAt: story.CreatedAt}
+	if err := h.cache.FanOut(ctx, entry, recipients); err != nil {
+		h.logger.Error("failed to fan out story", zap.Error(err))
+		return
+	}
+
+	// Only flag the story once every timeline has it; until then the read
+	// path keeps pulling it from Postgres.
+	if _, err := h.db.ExecContext(ctx, "UPDATE stories SET fanned_out = true WHERE id = $1", story.ID); err != nil {
+		h.logger.Error("failed to mark story fanned out", zap.Error(err))
+	}
+}
+
+// feedStories returns up to n visible stories older than the cursor, merging
+// the viewer's pushed Redis timeline with stories pulled from Postgres:
+// those of authors who were not fanned out, strangers' public stories,
+// explicit audience lists, and fanned-out stories older than anything left on
+// the timeline. Without Redis, or when the timeline is missing, every story
+// is pulled.
+func (h *StoriesHandler) feedStories(ctx context.Context, userID uuid.UUID, cursor pagination.Cursor, n int) ([]models.Story, error) {
+	var pushed []models.Story
+	var pushedSince *time.Time
+	if h.cache != nil {
+		var err error
+		pushed, err = h.timelineStories(ctx, userID, cursor, n)
+		// The floor is read after the timeline so that a trim in between
+		// can only make it newer, which pulls more, never less.
+		var floor time.Time
+		var ok bool
+		if err == nil {
+			floor, ok, err = h.cache.TimelineFloor(ctx, userID)
+		}
+		if err != nil {
+			h.logger.Warn("failed to read timeline, pulling full feed", zap.Error(err))
+		} else if ok {
+			pushedSince = &floor
+		}
+	}
+
+	pulled, err := h.pulledStories(ctx, userID, cursor, n, pushedSince)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(pushed)+len(pulled))
+	stories := make([]models.Story, 0, len(pushed)+len(pulled))
+	for _, story := range append(pushed, pulled...) {
+		if _, ok := seen[story.ID]; ok {
+			continue
+		}
+		seen[story.ID] = struct{}{}
+		stories = append(stories, story)
+	}
+
+	sort.Slice(stories, func(i, j int) bool {
+		if !stories[i].CreatedAt.Equal(stories[j].CreatedAt) {
+			return stories[i].CreatedAt.After(stories[j].CreatedAt)
+		}
+		return bytes.Compare(stories[i].ID[:], stories[j].ID[:]) > 0
+	})
+
+	if len(stories) > n {
+		stories = stories[:n]
+	}
+	return stories, nil
+}
+
+// timelineStories reads story IDs from the viewer's Redis timeline in
+// batches and hydrates them from Postgres, re-checking visibility, until n
+// stories older than the cursor are found or the timeline runs out.
+func (h *StoriesHandler) timelineStories(ctx context.Context, userID uuid.UUID, cursor pagination.Cursor, n int) ([]models.Story, error) {
+	stories := []models.Story{}
+	batch := int64(n)
+
+	for offset := int64(0); len(stories) < n; offset += batch {
+		storyIDs, err := h.cache.GetTimeline(ctx, userID, cursor.Time, offset, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(storyIDs) == 0 {
+			break
+		}
+
+		ids := make([]string, len(storyIDs))
+		for i, storyID := range storyIDs {
+			ids[i] = storyID.String()
+		}
+
+		rows, err := h.db.QueryContext(ctx, `
+			SELECT s.id, s.author_id, s.text, s.media_key, s.visibility, s.created_at, s.expires_at, s.edited_at
+			FROM stories s
+			WHERE s.id = ANY($2::uuid[])
+			  AND s.deleted_at IS NULL
+			  AND s.expires_at > NOW()
+			  AND `+visibility.FeedPredicate("s", "$1")+`
+			  AND (s.created_at, s.id) < ($3, $4)
+		`, userID, pq.Array(ids), cursor.Time, cursor.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := h.scanStories(rows)
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, found...)
+
+		if int64(len(storyIDs)) < batch {
+			break
+		}
+	}
+
+	return stories, nil
+}
+
+// pulledStories queries Postgres for feed stories older than the cursor.
+// With pushedSince set it skips the stories the viewer's timeline still
+// holds: fanned-out stories by the viewer or by authors they follow, created
+// at or after the timeline's oldest entry.
+func (h *StoriesHandler) pulledStories(ctx context.Context, userID uuid.UUID, cursor pagination.Cursor, n int, pushedSince *time.Time) ([]models.Story, error) {
+	args := []interface{}{userID, cursor.Time, cursor.ID, n}
+	skipPushed := ""
+	if pushedSince != nil {
+		args = append(args, *pushedSince)
+		skipPushed = `
+		  AND NOT (s.fanned_out AND s.created_at >= $5 AND (
+			s.author_id = $1
+			OR EXISTS (
+				SELECT 1 FROM follows f
+				WHERE f.follower_id = $1 AND f.followee_id = s.author_id AND f.status = 'accepted'
+			)
+		  ))`
+	}
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT s.id, s.author_id, s.text, s.media_key, s.visibility, s.created_at, s.expires_at, s.edited_at
+		FROM stories s
+		WHERE s.deleted_at IS NULL
+		  AND s.expires_at > NOW()
+		  AND `+visibility.FeedPredicate("s", "$1")+skipPushed+`
+		  AND (s.created_at, s.id) < ($2, $3)
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT $4
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.scanStories(rows)
+}
+
+func (h *StoriesHandler) scanStories(rows *sql.Rows) ([]models.Story, error) {
+	defer rows.Close()
+
+	stories := []models.Story{}
+	for rows.Next() {
+		var story models.Story
+		err := rows.Scan(&story.ID, &story.AuthorID, &story.Text, &story.MediaKey,
+			&story.Visibility, &story.CreatedAt, &story.ExpiresAt, &story.EditedAt)
+		if err != nil {
+			h.logger.Error("failed to scan story", zap.Error(err))
+			continue
+		}
+		stories = append(stories, story)
+	}
+	return stories, rows.Err()
+}
+
+func (h *StoriesHandler) ViewStory(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	// The body is optional; clients that track playback send it when the
+	// viewer finishes or skips the story.
+	var req models.ViewStoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	story, ok := h.loadVisibleStory(c, userID, storyID)
+	if !ok {
+		return
+	}
+	authorID := story.AuthorID
+
+	// Repeat views keep the first viewed_at. GREATEST skips NULLs, so a
+	// signal once reported true stays true.
+	var inserted bool
+	err = h.db.QueryRow(`
+		INSERT INTO story_views (story_id, viewer_id, viewed_at, completed, tapped_through)
+		VALUES ($1, $2, NOW(), $3, $4)
+		ON CONFLICT (story_id, viewer_id) DO UPDATE SET
+		  completed = GREATEST(story_views.completed, EXCLUDED.completed),
+		  tapped_through = GREATEST(story_views.tapped_through, EXCLUDED.tapped_through)
+		RETURNING (xmax = 0)
+	`, storyID, userID, req.Completed, req.TappedThrough).Scan(&inserted)
+
+	if err != nil {
+		h.logger.Error("failed to record view", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	metrics.StoryViewsTotal.Inc()
+
+	// Only a viewer's first view is news to the author; repeat views would
+	// fill their inbox with duplicates.
+	if inserted {
+		h.cache.IncrViewerCount(c.Request.Context(), storyID)
+
+		h.hub.SendToUser(authorID, websocket.Event{
+			Type: "story.viewed",
+			Payload: websocket.ViewEvent{
+				StoryID:  storyID,
+				ViewerID: userID,
+				ViewedAt: time.Now().Format(time.RFC3339),
+			},
+		})
+	}
+
+	h.logger.Info("story viewed",
+		zap.String("story_id", storyID.String()),
+		zap.String("viewer_id", userID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "view recorded"})
+}
+
+func (h *StoriesHandler) GetViewers(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor := pagination.Cursor{Time: time.Now().Add(time.Hour), ID: uuid.Max}
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		cursor, err = h.cursors.Decode(rawCursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	if !h.authorizeAuthor(c, userID, storyID) {
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT sv.viewer_id, sv.viewed_at, r.emoji
+		FROM story_views sv
+		LEFT JOIN reactions r ON r.story_id = sv.story_id AND r.user_id = sv.viewer_id
+		WHERE sv.story_id = $1
+		  AND (sv.viewed_at, sv.viewer_id) < ($2, $3)
+		ORDER BY sv.viewed_at DESC, sv.viewer_id DESC
+		LIMIT $4
+	`, storyID, cursor.Time, cursor.ID, limit+1)
+
+	if err != nil {
+		h.logger.Error("failed to get viewers", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	viewers := []models.StoryViewer{}
+	for rows.Next() {
+		var viewer models.StoryViewer
+		if err := rows.Scan(&viewer.ViewerID, &viewer.ViewedAt, &viewer.Reaction); err != nil {
+			h.logger.Error("failed to scan viewer", zap.Error(err))
+			continue
+		}
+		viewers = append(viewers, viewer)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate viewers", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := models.ViewersResponse{Viewers: viewers}
+	if len(viewers) > limit {
+		resp.Viewers = viewers[:limit]
+		last := resp.Viewers[limit-1]
+		next := h.cursors.Encode(pagination.Cursor{Time: last.ViewedAt, ID: last.ViewerID})
+		resp.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *StoriesHandler) GetViewerCount(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	if !h.authorizeAuthor(c, userID, storyID) {
+		return
+	}
+
+	count, err := h.cache.GetViewerCount(c.Request.Context(), storyID)
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{"story_id": storyID, "count": count, "cached": true})
+		return
+	}
+
+	err = h.db.QueryRow("SELECT COUNT(*) FROM story_views WHERE story_id = $1", storyID).Scan(&count)
+	if err != nil {
+		h.logger.Error("failed to count viewers", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.cache.SetViewerCount(c.Request.Context(), storyID, count)
+
+	c.JSON(http.StatusOK, gin.H{"story_id": storyID, "count": count})
+}
+
+// GetPresence returns who has the story open right now, as reported by
+// watch frames on /ws. Only the author may see it.
+func (h *StoriesHandler) GetPresence(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	if !h.authorizeAuthor(c, userID, storyID) {
+		return
+	}
+
+	if h.cache == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "presence unavailable"})
+		return
+	}
+
+	viewers, err := h.cache.StoryWatchers(c.Request.Context(), storyID)
+	if err != nil {
+		h.logger.Error("failed to get story watchers", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StoryPresence{
+		StoryID:  storyID,
+		Watching: len(viewers),
+		Viewers:  viewers,
+	})
+}
+
+// authorizeAuthor checks that the caller wrote the story, including expired
+// ones. On failure it writes the error response and returns false.
+func (h *StoriesHandler) authorizeAuthor(c *gin.Context, userID, storyID uuid.UUID) bool {
+	var authorID uuid.UUID
+	err := h.db.QueryRow("SELECT author_id FROM stories WHERE id = $1", storyID).Scan(&authorID)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story not found"})
+		return false
+	}
+	if err != nil {
+		h.logger.Error("failed to get story", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+
+	if authorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return false
+	}
+
+	return true
+}
+
+func (h *StoriesHandler) AddReaction(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	allowed, err := h.cache.CheckRateLimit(c.Request.Context(), userID, "react", 60, time.Minute)
+	if err != nil {
+		h.logger.Error("rate limit check failed", zap.Error(err))
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	var req models.ReactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.reactions.Allows(req.Emoji) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "emoji is not in the reaction catalogue"})
+		return
+	}
+
+	story, ok := h.loadVisibleStory(c, userID, storyID)
+	if !ok {
+		return
+	}
+	authorID := story.AuthorID
+
+	// One reaction per user per story: reacting again replaces the emoji.
+	// Reacting with the same emoji leaves the row alone and returns nothing.
+	var reactionID uuid.UUID
+	var inserted bool
+	err = h.db.QueryRow(`
+		INSERT INTO reactions (story_id, user_id, emoji)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (story_id, user_id) DO UPDATE SET emoji = EXCLUDED.emoji, created_at = NOW()
+		WHERE reactions.emoji <> EXCLUDED.emoji
+		RETURNING id, (xmax = 0)
+	`, storyID, userID, req.Emoji).Scan(&reactionID, &inserted)
+
+	if err == sql.ErrNoRows {
+		err = h.db.QueryRow(`
+			SELECT id FROM reactions WHERE story_id = $1 AND user_id = $2
+		`, storyID, userID).Scan(&reactionID)
+		if err != nil {
+			h.logger.Error("failed to get reaction", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": reactionID, "emoji": req.Emoji})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to add reaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	status := http.StatusOK
+	if inserted {
+		status = http.StatusCreated
+		metrics.ReactionsTotal.Inc()
+	}
+
+	h.hub.SendToUser(authorID, websocket.Event{
+		Type: "story.reacted",
+		Payload: websocket.ReactionEvent{
+			StoryID: storyID,
+			UserID:  userID,
+			Emoji:   req.Emoji,
+		},
+	})
+
+	h.logger.Info("reaction added",
+		zap.String("story_id", storyID.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("emoji", req.Emoji),
+		zap.Bool("replaced", !inserted))
+
+	c.JSON(status, gin.H{"id": reactionID, "emoji": req.Emoji})
+}
+
+func (h *StoriesHandler) RemoveReaction(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	var emoji string
+	var authorID uuid.UUID
+	err = h.db.QueryRow(`
+		DELETE FROM reactions r
+		USING stories s
+		WHERE r.story_id = $1 AND r.user_id = $2 AND s.id = r.story_id
+		RETURNING r.emoji, s.author_id
+	`, storyID, userID).Scan(&emoji, &authorID)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "reaction not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to remove reaction", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	h.hub.SendToUser(authorID, websocket.Event{
+		Type: "story.reaction_removed",
+		Payload: websocket.ReactionEvent{
+			StoryID: storyID,
+			UserID:  userID,
+			Emoji:   emoji,
+		},
 	})
+
+	h.logger.Info("reaction removed",
+		zap.String("story_id", storyID.String()),
+		zap.String("user_id", userID.String()))
+
+	c.JSON(http.StatusOK, gin.H{"message": "reaction removed"})
+}
+
+// GetStats reports an author's activity over [from, to) as totals plus an
+// hourly or daily series, read from the hourly rollups the worker maintains.
+// Every bucket and total comes from a single query: the grouping sets add the
+// window totals and the per-emoji breakdown, and unique viewers are counted
+// distinctly across the whole window rather than summed over buckets.
+func (h *StoriesHandler) GetStats(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	window, err := parseStatsWindow(c.Query("from"), c.Query("to"), c.Query("granularity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := h.db.Query(`
+		WITH buckets AS (
+			SELECT generate_series(
+				date_trunc($4, $2::timestamptz),
+				date_trunc($4, $3::timestamptz - INTERVAL '1 microsecond'),
+				('1 ' || $4)::interval
+			) AS bucket
+		),
+		events AS (
+			SELECT date_trunc($4, a.bucket) AS bucket, a.posted, a.views, a.reactions,
+				NULL::uuid AS viewer_id, NULL::text AS emoji, 0 AS emoji_reactions
+			FROM author_stats_hourly a
+			WHERE a.author_id = $1 AND a.bucket >= $2 AND a.bucket < $3
+			UNION ALL
+			SELECT date_trunc($4, v.bucket), 0, 0, 0, v.viewer_id, NULL, 0
+			FROM author_viewers_hourly v
+			WHERE v.author_id = $1 AND v.bucket >= $2 AND v.bucket < $3
+			UNION ALL
+			SELECT date_trunc($4, r.bucket), 0, 0, 0, NULL, r.emoji, r.reactions
+			FROM reaction_stats_hourly r
+			WHERE r.author_id = $1 AND r.bucket >= $2 AND r.bucket < $3
+		)
+		SELECT b.bucket, e.emoji, GROUPING(b.bucket), GROUPING(e.emoji),
+			COALESCE(SUM(e.posted), 0),
+			COALESCE(SUM(e.views), 0),
+			COUNT(DISTINCT e.viewer_id),
+			COALESCE(SUM(e.reactions), 0),
+			COALESCE(SUM(e.emoji_reactions), 0)
+		FROM buckets b
+		LEFT JOIN events e ON e.bucket = b.bucket
+		GROUP BY GROUPING SETS ((b.bucket), (), (e.emoji))
+		ORDER BY b.bucket
+	`, userID, window.From, window.To, window.Granularity)
+
+	if err != nil {
+		h.logger.Error("failed to get stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	stats := models.StatsResponse{
+		From:        window.From,
+		To:          window.To,
+		Granularity: window.Granularity,
+		Reactions:   make(map[string]int),
+		Series:      []models.StatsBucket{},
+	}
+
+	// Report every emoji in the catalogue, including unused ones, and leave
+	// out emojis that have since been retired from it.
+	for _, emoji := range h.reactions.Emojis() {
+		stats.Reactions[emoji] = 0
+	}
+
+	for rows.Next() {
+		var bucket models.StatsBucket
+		var bucketStart *time.Time
+		var emoji *string
+		var overBuckets, overEmojis, emojiReactions int
+		err := rows.Scan(&bucketStart, &emoji, &overBuckets, &overEmojis,
+			&bucket.Posted, &bucket.Views, &bucket.UniqueViewers, &bucket.Reactions, &emojiReactions)
+		if err != nil {
+			h.logger.Error("failed to scan stats", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		switch {
+		case overBuckets == 0:
+			bucket.Start = *bucketStart
+			stats.Series = append(stats.Series, bucket)
+		case overEmojis == 1:
+			stats.Posted = bucket.Posted
+			stats.Views = bucket.Views
+			stats.UniqueViewers = bucket.UniqueViewers
+		case emoji != nil && h.reactions.Allows(*emoji):
+			stats.Reactions[*emoji] = emojiReactions
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+type statsWindow struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+}
+
+// parseStatsWindow reads the RFC 3339 from/to bounds and granularity for
+// GET /me/stats, defaulting to the last 7 days by day. Windows are capped so
+// a series never exceeds a year of days or a week of hours.
+func parseStatsWindow(rawFrom, rawTo, granularity string) (statsWindow, error) {
+	window := statsWindow{To: time.Now().UTC(), Granularity: granularity}
+
+	if rawTo != "" {
+		to, err := time.Parse(time.RFC3339, rawTo)
+		if err != nil {
+			return window, fmt.Errorf("invalid to: must be an RFC 3339 timestamp")
+		}
+		window.To = to
+	}
+
+	window.From = window.To.Add(-7 * 24 * time.Hour)
+	if rawFrom != "" {
+		from, err := time.Parse(time.RFC3339, rawFrom)
+		if err != nil {
+			return window, fmt.Errorf("invalid from: must be an RFC 3339 timestamp")
+		}
+		window.From = from
+	}
+
+	if !window.From.Before(window.To) {
+		return window, fmt.Errorf("from must be before to")
+	}
+
+	var maxWindow time.Duration
+	switch window.Granularity {
+	case "", "day":
+		window.Granularity = "day"
+		maxWindow = 366 * 24 * time.Hour
+	case "hour":
+		maxWindow = 7 * 24 * time.Hour
+	default:
+		return window, fmt.Errorf("granularity must be hour or day")
+	}
+
+	if window.To.Sub(window.From) > maxWindow {
+		return window, fmt.Errorf("window too large for %s granularity", window.Granularity)
+	}
+
+	// Rollups are hourly, so widen the window to the whole hours it touches
+	// and report that window rather than count activity outside the one asked
+	// for without saying so.
+	window.From = window.From.Truncate(time.Hour)
+	if to := window.To.Truncate(time.Hour); to.Before(window.To) {
+		window.To = to.Add(time.Hour)
+	}
+
+	return window, nil
+}
+
+// GetInsights reports analytics for one story to its author, including
+// after it has expired. Viewers count once, at their first view; reach is
+// split by whether each viewer follows the author now.
+func (h *StoriesHandler) GetInsights(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	storyIDStr := c.Param("id")
+	storyID, err := uuid.Parse(storyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid story id"})
+		return
+	}
+
+	if !h.authorizeAuthor(c, userID, storyID) {
+		return
+	}
+
+	insights := models.StoryInsights{StoryID: storyID}
+	var engagement models.EngagementStats
+	err = h.db.QueryRow(`
+		SELECT COUNT(*),
+		  COUNT(*) FILTER (WHERE sv.completed IS NOT NULL OR sv.tapped_through IS NOT NULL),
+		  COUNT(*) FILTER (WHERE sv.completed),
+		  COUNT(*) FILTER (WHERE sv.tapped_through),
+		  COUNT(*) FILTER (WHERE EXISTS (
+		    SELECT 1 FROM follows f
+		    WHERE f.follower_id = sv.viewer_id AND f.followee_id = s.author_id AND f.status = 'accepted'
+		  ))
+		FROM story_views sv
+		JOIN stories s ON s.id = sv.story_id
+		WHERE sv.story_id = $1
+	`, storyID).Scan(&insights.UniqueViewers, &engagement.Reported, &engagement.Completed,
+		&engagement.TappedThrough, &insights.Reach.Followers)
+
+	if err != nil {
+		h.logger.Error("failed to get story insights", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	insights.Reach.NonFollowers = insights.UniqueViewers - insights.Reach.Followers
+	if engagement.Reported > 0 {
+		engagement.CompletionRate = float64(engagement.Completed) / float64(engagement.Reported)
+		engagement.TapThroughRate = float64(engagement.TappedThrough) / float64(engagement.Reported)
+		insights.Engagement = &engagement
+	}
+
+	// One bucket per hour the story was live, including hours with no views.
+	rows, err := h.db.Query(`
+		SELECT b.hour, COUNT(sv.viewer_id)
+		FROM stories s
+		CROSS JOIN LATERAL generate_series(
+		  date_trunc('hour', s.created_at),
+		  date_trunc('hour', LEAST(NOW(), s.expires_at, s.deleted_at)),
+		  INTERVAL '1 hour'
+		) AS b(hour)
+		LEFT JOIN story_views sv
+		  ON sv.story_id = s.id AND date_trunc('hour', sv.viewed_at) = b.hour
+		WHERE s.id = $1
+		GROUP BY b.hour
+		ORDER BY b.hour
+	`, storyID)
+
+	if err != nil {
+		h.logger.Error("failed to get hourly views", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer rows.Close()
+
+	insights.ViewsByHour = []models.HourlyViewers{}
+	for rows.Next() {
+		var bucket models.HourlyViewers
+		if err := rows.Scan(&bucket.Hour, &bucket.NewViewers); err != nil {
+			h.logger.Error("failed to scan hourly views", zap.Error(err))
+			continue
+		}
+		insights.ViewsByHour = append(insights.ViewsByHour, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		h.logger.Error("failed to iterate hourly views", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	reactionRows, err := h.db.Query(`
+		SELECT emoji, COUNT(*) FROM reactions
+		WHERE story_id = $1
+		GROUP BY emoji
+	`, storyID)
+
+	if err != nil {
+		h.logger.Error("failed to get reaction breakdown", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	defer reactionRows.Close()
+
+	insights.Reactions = make(map[string]int)
+	for _, emoji := range h.reactions.Emojis() {
+		insights.Reactions[emoji] = 0
+	}
+	for reactionRows.Next() {
+		var emoji string
+		var count int
+		if err := reactionRows.Scan(&emoji, &count); err != nil {
+			h.logger.Error("failed to scan reaction count", zap.Error(err))
+			continue
+		}
+		if h.reactions.Allows(emoji) {
+			insights.Reactions[emoji] = count
+		}
+	}
+
+	if err := reactionRows.Err(); err != nil {
+		h.logger.Error("failed to iterate reaction breakdown", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, insights)
+}
+
+func (h *StoriesHandler) GetReactionCatalogue(c *gin.Context) {
+	c.JSON(http.StatusOK, models.ReactionCatalogueResponse{Emojis: h.reactions.Emojis()})
 }
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stories-service/internal/middleware"
+	"stories-service/internal/notifications"
+	"stories-service/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	ws "github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// replayLimit caps how many stored events are replayed on connect. When more
+// are waiting, the replay ends with a replay_truncated frame and the client
+// catches up through /me/notifications.
+const replayLimit = 500
+
+// heartbeatInterval is how often an idle event stream gets a comment line,
+// so proxies do not close it for inactivity.
+const heartbeatInterval = 15 * time.Second
+
+var upgrader = ws.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// StreamHandler serves the hub's events over WebSocket (/ws) and
+// Server-Sent Events (/events). Both carry the same event envelopes and
+// resume from the user's inbox the same way.
+type StreamHandler struct {
+	hub    *websocket.Hub
+	inbox  *notifications.Store
+	logger *zap.Logger
+}
+
+func NewStreamHandler(hub *websocket.Hub, inbox *notifications.Store, logger *zap.Logger) *StreamHandler {
+	return &StreamHandler{
+		hub:    hub,
+		inbox:  inbox,
+		logger: logger,
+	}
+}
+
+// WebSocket upgrades the request to a WebSocket. With ?last_seq=N the events
+// stored after sequence N are written first, then live events follow without
+// gaps or duplicates.
+func (h *StreamHandler) WebSocket(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	lastSeq, ok := parseLastSeq(c, "last_seq", c.Query("last_seq"))
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.Error("websocket upgrade failed", zap.Error(err))
+		return
+	}
+
+	client := websocket.NewClient(userID, h.hub, conn)
+	h.hub.RegisterClient(client)
+
+	backlog, through, err := h.loadBacklog(c.Request.Context(), userID, lastSeq)
+	if err != nil {
+		h.logger.Error("failed to load events for replay", zap.Error(err))
+		conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseInternalServerErr, "replay failed"))
+		h.hub.UnregisterClient(client)
+		conn.Close()
+		return
+	}
+	client.Replay(backlog, through)
+
+	go client.WritePump()
+	go client.ReadPump()
+}
+
+// Events streams the user's events as Server-Sent Events. Each event's id is
+// its inbox sequence number, so a reconnecting EventSource resumes through
+// the Last-Event-ID header; clients that cannot set it pass ?last_seq=N.
+func (h *StreamHandler) Events(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	name, raw := "Last-Event-ID", c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		name, raw = "last_seq", c.Query("last_seq")
+	}
+	lastSeq, ok := parseLastSeq(c, name, raw)
+	if !ok {
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming unsupported"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	client := websocket.NewStreamClient(userID, h.hub)
+	h.hub.RegisterClient(client)
+	defer h.hub.UnregisterClient(client)
+
+	// Loaded after registering, so an event stored in between arrives both
+	// ways and the live copy is skipped.
+	backlog, replayedTo, err := h.loadBacklog(ctx, userID, lastSeq)
+	if err != nil {
+		h.logger.Error("failed to load events for replay", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, message := range backlog {
+		if err := writeServerSentEvent(c.Writer, message); err != nil {
+			return
+		}
+	}
+	// Tell EventSource to wait a few seconds before reconnecting.
+	if _, err := fmt.Fprint(c.Writer, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	events := client.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case message, ok := <-events:
+			// Closed when the hub drops a stream that fell too far behind;
+			// the client reconnects and resumes from its last event id.
+			if !ok {
+				return
+			}
+			if message.Seq != 0 && message.Seq <= replayedTo {
+				continue
+			}
+			if err := writeServerSentEvent(c.Writer, message); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// loadBacklog returns the events stored after lastSeq, or nothing when the
+// client did not ask for a replay (lastSeq < 0), and the sequence number live
+// events must exceed to be sent. If more than replayLimit events are waiting,
+// the backlog ends with a replay_truncated frame: its seq is the first event
+// not replayed and its up_to_seq the latest event stored, which live events
+// will not repeat, so the client knows exactly which range to fetch.
+func (h *StreamHandler) loadBacklog(ctx context.Context, userID uuid.UUID, lastSeq int64) ([]*websocket.Message, int64, error) {
+	if lastSeq < 0 {
+		return nil, 0, nil
+	}
+
+	stored, err := h.inbox.Since(ctx, userID, lastSeq, replayLimit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	truncated := len(stored) > replayLimit
+	if truncated {
+		stored = stored[:replayLimit]
+	}
+
+	backlog := make([]*websocket.Message, 0, len(stored))
+	for _, n := range stored {
+		payload, err := json.Marshal(websocket.Event{Seq: n.Seq, Type: n.Type, Payload: n.Payload})
+		if err != nil {
+			continue
+		}
+		backlog = append(backlog, &websocket.Message{Seq: n.Seq, Payload: payload})
+	}
+
+	through := lastSeq
+	if len(stored) > 0 {
+		through = stored[len(stored)-1].Seq
+	}
+	if !truncated {
+		return backlog, through, nil
+	}
+
+	upTo, err := h.inbox.LastSeq(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	frame, err := json.Marshal(websocket.Frame{Type: "replay_truncated", Seq: through + 1, UpToSeq: upTo})
+	if err != nil {
+		return nil, 0, err
+	}
+	backlog = append(backlog, &websocket.Message{Payload: frame})
+	return backlog, upTo, nil
+}
+
+// parseLastSeq reads the sequence number a client last saw. An empty value
+// means no replay and yields -1.
+func parseLastSeq(c *gin.Context, name, raw string) (int64, bool) {
+	if raw == "" {
+		return -1, true
+	}
+
+	seq, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seq < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a non-negative integer"})
+		return 0, false
+	}
+	return seq, true
+}
+
+// writeServerSentEvent writes one hub message as an unnamed SSE event, so
+// EventSource.onmessage receives every type. The data line is the same JSON
+// envelope sent over the WebSocket.
+func writeServerSentEvent(w gin.ResponseWriter, message *websocket.Message) error {
+	if message.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", message.Seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", message.Payload)
+	return err
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"stories-service/internal/visibility"
+
+	"github.com/google/uuid"
+)
+
+// TestCanViewAuthor checks author topic access against a real database, so
+// the query's parameter types are resolved by Postgres.
+func TestCanViewAuthor(t *testing.T) {
+	database := newTestDB(t)
+	access := visibility.NewEvaluator(database)
+	ctx := context.Background()
+
+	viewerID := createTestUser(t, database)
+	publicID := createTestUser(t, database)
+	privateID := createTestUser(t, database)
+	followedID := createTestUser(t, database)
+	blockerID := createTestUser(t, database)
+
+	for _, id := range []uuid.UUID{privateID, followedID} {
+		if _, err := database.Exec(`UPDATE users SET is_private = true WHERE id = $1`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createTestFollow(t, database, viewerID, followedID)
+	if _, err := database.Exec(`INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2)`,
+		blockerID, viewerID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		authorID uuid.UUID
+		want     bool
+	}{
+		{"self", viewerID, true},
+		{"public account", publicID, true},
+		{"private account", privateID, false},
+		{"private account followed", followedID, true},
+		{"blocked by author", blockerID, false},
+		{"unknown author", uuid.New(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := access.CanViewAuthor(ctx, viewerID, tt.authorID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CanViewAuthor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -42,6 +42,13 @@ var (
 		},
 	)
 
+	RevocationCheckErrorsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_revocation_check_errors_total",
+			Help: "Total number of failed token revocation checks",
+		},
+	)
+
 	WorkerLatencySeconds = promauto.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:    "worker_latency_seconds",
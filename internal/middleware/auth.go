@@ -5,12 +5,19 @@ import (
 	"strings"
 
 	"stories-service/internal/auth"
+	"stories-service/internal/cache"
+	"stories-service/internal/metrics"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// AuthMiddleware authenticates requests by their bearer token and rejects
+// tokens revoked in Redis. If the revocation list cannot be read the request
+// is refused with 503, unless failOpen is set, in which case it is let
+// through.
+func AuthMiddleware(jwtSecret string, cach *cache.Cache, failOpen bool, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -19,24 +26,89 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		token, ok := bearerToken(authHeader)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
 			c.Abort()
 			return
 		}
 
-		claims, err := auth.ValidateToken(parts[1], jwtSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		authenticate(c, token, jwtSecret, cach, failOpen, logger)
+	}
+}
+
+// StreamAuthMiddleware authenticates the real-time routes. Browsers cannot
+// set headers on WebSocket or EventSource connections, so besides the
+// Authorization header it accepts the access token in the access_token query
+// parameter.
+func StreamAuthMiddleware(jwtSecret string, cach *cache.Cache, failOpen bool, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("access_token")
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			var ok bool
+			token, ok = bearerToken(authHeader)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+				c.Abort()
+				return
+			}
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header or access_token required"})
+			c.Abort()
+			return
+		}
+
+		authenticate(c, token, jwtSecret, cach, failOpen, logger)
+	}
+}
+
+func bearerToken(authHeader string) (string, bool) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func authenticate(c *gin.Context, token, jwtSecret string, cach *cache.Cache, failOpen bool, logger *zap.Logger) {
+	claims, err := auth.ValidateToken(token, jwtSecret)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	revoked, err := cach.IsTokenRevoked(c.Request.Context(), claims.ID, claims.FamilyID)
+	if err != nil {
+		metrics.RevocationCheckErrorsTotal.Inc()
+		logger.Error("failed to check token revocation",
+			zap.String("user_id", claims.UserID.String()), zap.Bool("fail_open", failOpen), zap.Error(err))
+		if !failOpen {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "could not verify token"})
 			c.Abort()
 			return
 		}
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+		c.Abort()
+		return
+	}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Next()
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("token_claims", claims)
+	c.Next()
+}
+
+func GetTokenClaims(c *gin.Context) (*auth.JWTClaims, bool) {
+	claims, exists := c.Get("token_claims")
+	if !exists {
+		return nil, false
 	}
+	jwtClaims, ok := claims.(*auth.JWTClaims)
+	return jwtClaims, ok
 }
 
 func GetUserID(c *gin.Context) (uuid.UUID, bool) {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +11,7 @@ type User struct {
 	ID           uuid.UUID `json:"id" db:"id"`
 	Email        string    `json:"email" db:"email"`
 	PasswordHash string    `json:"-" db:"password_hash"`
+	IsPrivate    bool      `json:"is_private" db:"is_private"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 }
 
@@ -21,21 +23,55 @@ type Story struct {
 	Visibility string     `json:"visibility" db:"visibility"`
 	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty" db:"edited_at"`
 	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type Follow struct {
 	FollowerID uuid.UUID `json:"follower_id" db:"follower_id"`
 	FolloweeID uuid.UUID `json:"followee_id" db:"followee_id"`
+	Status     string    `json:"status" db:"status"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
+type FollowUser struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+	FollowsYou bool      `json:"follows_you"`
+	Following  bool      `json:"following"`
+}
+
+type FollowListResponse struct {
+	Users      []FollowUser `json:"users"`
+	NextCursor *string      `json:"next_cursor"`
+}
+
+type UserProfile struct {
+	UserID         uuid.UUID `json:"user_id"`
+	IsPrivate      bool      `json:"is_private"`
+	FollowersCount int       `json:"followers_count"`
+	FollowingCount int       `json:"following_count"`
+	FollowsYou     bool      `json:"follows_you"`
+	Following      bool      `json:"following"`
+	Requested      bool      `json:"requested"`
+}
+
+type PrivacyRequest struct {
+	Private *bool `json:"private" binding:"required"`
+}
+
 type StoryView struct {
 	StoryID  uuid.UUID `json:"story_id" db:"story_id"`
 	ViewerID uuid.UUID `json:"viewer_id" db:"viewer_id"`
 	ViewedAt time.Time `json:"viewed_at" db:"viewed_at"`
 }
 
+type StoryViewer struct {
+	ViewerID uuid.UUID `json:"viewer_id"`
+	ViewedAt time.Time `json:"viewed_at"`
+	Reaction *string   `json:"reaction"`
+}
+
 type Reaction struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	StoryID   uuid.UUID `json:"story_id" db:"story_id"`
@@ -49,22 +85,180 @@ type StoryAudience struct {
 	UserID  uuid.UUID `json:"user_id" db:"user_id"`
 }
 
+type Highlight struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	OwnerID    uuid.UUID `json:"owner_id" db:"owner_id"`
+	Title      string    `json:"title" db:"title"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	StoryCount int       `json:"story_count"`
+	Stories    []Story   `json:"stories,omitempty"`
+}
+
 type CreateStoryRequest struct {
 	Text            *string     `json:"text"`
 	MediaKey        *string     `json:"media_key"`
-	Visibility      string      `json:"visibility" binding:"required,oneof=public friends private"`
+	Visibility      string      `json:"visibility" binding:"required,oneof=public friends mutuals private"`
 	AudienceUserIDs []uuid.UUID `json:"audience_user_ids,omitempty"`
+	Duration        *int        `json:"duration,omitempty" binding:"omitempty,min=1"`
+}
+
+type UpdateStoryRequest struct {
+	Text            *string      `json:"text"`
+	Visibility      *string      `json:"visibility" binding:"omitempty,oneof=public friends mutuals private"`
+	AudienceUserIDs *[]uuid.UUID `json:"audience_user_ids"`
+}
+
+type HighlightRequest struct {
+	Title string `json:"title" binding:"required,max=100"`
+}
+
+type AddHighlightStoryRequest struct {
+	StoryID uuid.UUID `json:"story_id" binding:"required"`
+}
+
+type FeedResponse struct {
+	Stories    []Story `json:"stories"`
+	NextCursor *string `json:"next_cursor"`
+	Cached     bool    `json:"cached,omitempty"`
+}
+
+type FeedStory struct {
+	Story
+	Seen bool `json:"seen"`
+}
+
+type AuthorStories struct {
+	AuthorID  uuid.UUID   `json:"author_id"`
+	HasUnseen bool        `json:"has_unseen"`
+	LatestAt  time.Time   `json:"latest_at"`
+	Stories   []FeedStory `json:"stories"`
+}
+
+type GroupedFeedResponse struct {
+	Authors    []AuthorStories `json:"authors"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+type ViewersResponse struct {
+	Viewers    []StoryViewer `json:"viewers"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+type ReplyRequest struct {
+	Text string `json:"text" binding:"required,max=1000"`
+}
+
+type ReplyMessage struct {
+	ID        uuid.UUID `json:"id"`
+	ThreadID  uuid.UUID `json:"thread_id"`
+	SenderID  uuid.UUID `json:"sender_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ReplyThread struct {
+	ID            uuid.UUID     `json:"id"`
+	StoryID       uuid.UUID     `json:"story_id"`
+	AuthorID      uuid.UUID     `json:"author_id"`
+	ViewerID      uuid.UUID     `json:"viewer_id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	LastMessageAt time.Time     `json:"last_message_at"`
+	LastMessage   *ReplyMessage `json:"last_message,omitempty"`
+}
+
+type ReplyThreadsResponse struct {
+	Threads    []ReplyThread `json:"threads"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+type ReplyMessagesResponse struct {
+	Messages   []ReplyMessage `json:"messages"`
+	NextCursor *string        `json:"next_cursor"`
 }
 
 type ReactRequest struct {
-	Emoji string `json:"emoji" binding:"required,oneof=👍 ❤️ 😂 😮 😢 🔥"`
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// ViewStoryRequest carries optional playback signals reported by clients.
+type ViewStoryRequest struct {
+	Completed     *bool `json:"completed"`
+	TappedThrough *bool `json:"tapped_through"`
+}
+
+type HourlyViewers struct {
+	Hour       time.Time `json:"hour"`
+	NewViewers int       `json:"new_viewers"`
+}
+
+type EngagementStats struct {
+	Reported       int     `json:"reported"`
+	Completed      int     `json:"completed"`
+	TappedThrough  int     `json:"tapped_through"`
+	CompletionRate float64 `json:"completion_rate"`
+	TapThroughRate float64 `json:"tap_through_rate"`
+}
+
+type ReachStats struct {
+	Followers    int `json:"followers"`
+	NonFollowers int `json:"non_followers"`
+}
+
+type StoryInsights struct {
+	StoryID       uuid.UUID        `json:"story_id"`
+	UniqueViewers int              `json:"unique_viewers"`
+	ViewsByHour   []HourlyViewers  `json:"views_by_hour"`
+	Engagement    *EngagementStats `json:"engagement"`
+	Reactions     map[string]int   `json:"reactions"`
+	Reach         ReachStats       `json:"reach"`
+}
+
+type StoryPresence struct {
+	StoryID  uuid.UUID   `json:"story_id"`
+	Watching int         `json:"watching"`
+	Viewers  []uuid.UUID `json:"viewers"`
+}
+
+type Notification struct {
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	ReadAt    *time.Time      `json:"read_at"`
+}
+
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	NextBeforeSeq *int64         `json:"next_before_seq"`
+}
+
+type MarkNotificationsReadRequest struct {
+	UpToSeq int64 `json:"up_to_seq" binding:"required,min=1"`
+}
+
+type ReactionCatalogueResponse struct {
+	Emojis []string `json:"emojis"`
+}
+
+type StatsBucket struct {
+	Start         time.Time `json:"start"`
+	Posted        int       `json:"posted"`
+	Views         int       `json:"views"`
+	UniqueViewers int       `json:"unique_viewers"`
+	Reactions     int       `json:"reactions"`
 }
 
 type StatsResponse struct {
-	Posted        int                `json:"posted"`
-	Views         int                `json:"views"`
-	UniqueViewers int                `json:"unique_viewers"`
-	Reactions     map[string]int     `json:"reactions"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Granularity   string         `json:"granularity"`
+	Posted        int            `json:"posted"`
+	Views         int            `json:"views"`
+	UniqueViewers int            `json:"unique_viewers"`
+	Reactions     map[string]int `json:"reactions"`
+	Series        []StatsBucket  `json:"series"`
 }
 
 type PresignedUploadRequest struct {
@@ -0,0 +1,139 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"stories-service/internal/db"
+	"stories-service/internal/models"
+	"stories-service/internal/websocket"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Store is the per-user event inbox. It implements websocket.Inbox so every
+// event sent through the hub is persisted before it is delivered.
+type Store struct {
+	db *db.DB
+}
+
+func NewStore(database *db.DB) *Store {
+	return &Store{db: database}
+}
+
+// Append stores the entries in one statement and sets each one's Seq. Each
+// user's counter is bumped once by their number of entries, which are then
+// numbered in the order given.
+func (s *Store) Append(ctx context.Context, entries []websocket.InboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	userIDs := make([]string, len(entries))
+	types := make([]string, len(entries))
+	payloads := make([]string, len(entries))
+	for i, e := range entries {
+		userIDs[i] = e.UserID.String()
+		types[i] = e.Type
+		payloads[i] = string(e.Payload)
+	}
+
+	// Counters are locked in user_id order so that concurrent batches from
+	// other replicas cannot deadlock on them.
+	rows, err := s.db.QueryContext(ctx, `
+		WITH input AS (
+			SELECT e.user_id, e.type, e.payload::jsonb AS payload, e.ord
+			FROM unnest($1::uuid[], $2::text[], $3::text[]) WITH ORDINALITY AS e(user_id, type, payload, ord)
+		),
+		counts AS (
+			SELECT user_id, COUNT(*) AS n FROM input GROUP BY user_id
+		),
+		bumped AS (
+			INSERT INTO notification_seqs (user_id, last_seq)
+			SELECT user_id, n FROM counts ORDER BY user_id
+			ON CONFLICT (user_id) DO UPDATE SET last_seq = notification_seqs.last_seq + EXCLUDED.last_seq
+			RETURNING user_id, last_seq
+		),
+		numbered AS (
+			SELECT i.ord, i.user_id, i.type, i.payload,
+			       b.last_seq - c.n + ROW_NUMBER() OVER (PARTITION BY i.user_id ORDER BY i.ord) AS seq
+			FROM input i
+			JOIN counts c ON c.user_id = i.user_id
+			JOIN bumped b ON b.user_id = i.user_id
+		),
+		stored AS (
+			INSERT INTO notifications (user_id, seq, type, payload)
+			SELECT user_id, seq, type, payload FROM numbered
+		)
+		SELECT ord, seq FROM numbered
+	`, pq.Array(userIDs), pq.Array(types), pq.Array(payloads))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ord, seq int64
+		if err := rows.Scan(&ord, &seq); err != nil {
+			return err
+		}
+		entries[ord-1].Seq = seq
+	}
+	return rows.Err()
+}
+
+// Since returns up to limit of the user's events with a sequence number above
+// afterSeq, oldest first.
+func (s *Store) Since(ctx context.Context, userID uuid.UUID, afterSeq int64, limit int) ([]models.Notification, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT seq, type, payload, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3
+	`, userID, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var payload []byte
+		if err := rows.Scan(&n.Seq, &n.Type, &payload, &n.CreatedAt, &n.ReadAt); err != nil {
+			return nil, err
+		}
+		n.Payload = json.RawMessage(payload)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// LastSeq returns the sequence number of the user's latest event, or 0 if
+// they have none.
+func (s *Store) LastSeq(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT last_seq FROM notification_seqs WHERE user_id = $1
+	`, userID).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// MarkRead marks the user's events up to and including upToSeq as read and
+// returns how many were unread.
+func (s *Store) MarkRead(ctx context.Context, userID uuid.UUID, upToSeq int64) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE notifications SET read_at = NOW()
+		WHERE user_id = $1 AND seq <= $2 AND read_at IS NULL
+	`, userID, upToSeq)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list ordered by (Time DESC, ID DESC).
+type Cursor struct {
+	Time time.Time
+	ID   uuid.UUID
+}
+
+// Codec turns cursors into opaque, tamper-proof strings so clients
+// cannot forge positions or depend on the encoding.
+type Codec struct {
+	secret []byte
+}
+
+func NewCodec(secret string) *Codec {
+	return &Codec{secret: []byte(secret)}
+}
+
+func (c *Codec) Encode(cursor Cursor) string {
+	payload := make([]byte, 8+16)
+	binary.BigEndian.PutUint64(payload[:8], uint64(cursor.Time.UnixMicro()))
+	copy(payload[8:], cursor.ID[:])
+
+	return base64.RawURLEncoding.EncodeToString(append(payload, c.sign(payload)...))
+}
+
+func (c *Codec) Decode(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) != 8+16+sha256.Size {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	payload, sig := data[:24], data[24:]
+	if !hmac.Equal(sig, c.sign(payload)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var id uuid.UUID
+	copy(id[:], payload[8:])
+
+	return Cursor{
+		Time: time.UnixMicro(int64(binary.BigEndian.Uint64(payload[:8]))).UTC(),
+		ID:   id,
+	}, nil
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ParseLimit reads a page size from a query value, falling back to
+// DefaultLimit and clamping to MaxLimit.
+func ParseLimit(raw string) (int, error) {
+	if raw == "" {
+		return DefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, errors.New("invalid limit")
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,96 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	codec := NewCodec("secret")
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"now", Cursor{Time: time.Now().UTC().Truncate(time.Microsecond), ID: uuid.New()}},
+		{"epoch", Cursor{Time: time.Unix(0, 0).UTC(), ID: uuid.Nil}},
+		{"max id", Cursor{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), ID: uuid.Max}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := codec.Decode(codec.Encode(tt.cursor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Time.Equal(tt.cursor.Time) || got.ID != tt.cursor.ID {
+				t.Errorf("Decode = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestCodecRejectsInvalidCursors(t *testing.T) {
+	codec := NewCodec("secret")
+	encoded := codec.Encode(Cursor{Time: time.Now(), ID: uuid.New()})
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flip := func(i int) string {
+		data := append([]byte(nil), raw...)
+		data[i] ^= 0xff
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"tampered time", flip(0)},
+		{"tampered id", flip(8)},
+		{"tampered signature", flip(len(raw) - 1)},
+		{"truncated", base64.RawURLEncoding.EncodeToString(raw[:len(raw)-1])},
+		{"other key", NewCodec("other").Encode(Cursor{Time: time.Now(), ID: uuid.New()})},
+		{"not base64", "not a cursor!"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := codec.Decode(tt.input); err != ErrInvalidCursor {
+				t.Errorf("Decode error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{"", DefaultLimit, false},
+		{"1", 1, false},
+		{"20", 20, false},
+		{"100", MaxLimit, false},
+		{"101", MaxLimit, false},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"ten", 0, true},
+		{"2.5", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParseLimit(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package presence
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"stories-service/internal/cache"
+	"stories-service/internal/db"
+	"stories-service/internal/websocket"
+
+	"github.com/google/uuid"
+)
+
+// Tracker records who is watching which story in Redis so every replica
+// sees the same watchers. It implements websocket.Presence.
+type Tracker struct {
+	db    *db.DB
+	cache *cache.Cache
+}
+
+func NewTracker(database *db.DB, cach *cache.Cache) *Tracker {
+	return &Tracker{db: database, cache: cach}
+}
+
+// StoryAuthor returns the author of an active story; ok is false when the
+// story is deleted, expired or does not exist.
+func (t *Tracker) StoryAuthor(ctx context.Context, storyID uuid.UUID) (uuid.UUID, bool, error) {
+	var authorID uuid.UUID
+	err := t.db.QueryRowContext(ctx, `
+		SELECT author_id FROM stories
+		WHERE id = $1 AND deleted_at IS NULL AND expires_at > NOW()
+	`, storyID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	return authorID, true, nil
+}
+
+func (t *Tracker) Watch(ctx context.Context, storyID uuid.UUID, watchers []websocket.Watcher, ttl time.Duration) (int64, int64, error) {
+	members := make([]string, len(watchers))
+	for i, w := range watchers {
+		members[i] = cache.WatcherMember(w.UserID, w.ConnID)
+	}
+	return t.cache.WatchStory(ctx, storyID, members, ttl)
+}
+
+func (t *Tracker) Unwatch(ctx context.Context, storyID uuid.UUID, watcher websocket.Watcher) (int64, error) {
+	return t.cache.UnwatchStory(ctx, storyID, cache.WatcherMember(watcher.UserID, watcher.ConnID))
+}
+
+func (t *Tracker) ExpireWatchers(ctx context.Context) (map[uuid.UUID]int64, error) {
+	return t.cache.ExpireWatchers(ctx)
+}
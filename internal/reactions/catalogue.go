@@ -0,0 +1,53 @@
+package reactions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Default is the palette used when a deployment does not configure its own.
+var Default = []string{"👍", "❤️", "😂", "😮", "😢", "🔥"}
+
+// Catalogue is the ordered set of emojis users may react with.
+type Catalogue struct {
+	emojis  []string
+	allowed map[string]struct{}
+}
+
+// NewCatalogue builds a catalogue from emojis in display order, trimming
+// surrounding whitespace and rejecting empty entries and duplicates.
+func NewCatalogue(emojis []string) (*Catalogue, error) {
+	if len(emojis) == 0 {
+		return nil, fmt.Errorf("reaction catalogue is empty")
+	}
+
+	catalogue := &Catalogue{
+		emojis:  make([]string, 0, len(emojis)),
+		allowed: make(map[string]struct{}, len(emojis)),
+	}
+	for _, emoji := range emojis {
+		emoji = strings.TrimSpace(emoji)
+		if emoji == "" {
+			return nil, fmt.Errorf("reaction catalogue contains an empty entry")
+		}
+		if _, ok := catalogue.allowed[emoji]; ok {
+			return nil, fmt.Errorf("reaction catalogue contains %s twice", emoji)
+		}
+		catalogue.allowed[emoji] = struct{}{}
+		catalogue.emojis = append(catalogue.emojis, emoji)
+	}
+
+	return catalogue, nil
+}
+
+// Emojis returns a copy of the catalogue in display order.
+func (c *Catalogue) Emojis() []string {
+	return append([]string(nil), c.emojis...)
+}
+
+// Allows reports whether emoji is in the catalogue. Only configured entries
+// are trimmed; emoji must match one exactly, since it is stored as sent.
+func (c *Catalogue) Allows(emoji string) bool {
+	_, ok := c.allowed[emoji]
+	return ok
+}
@@ -0,0 +1,73 @@
+package reactions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewCatalogue(t *testing.T) {
+	tests := []struct {
+		name    string
+		emojis  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default", emojis: Default, want: Default},
+		{name: "keeps order", emojis: []string{"🔥", "👍"}, want: []string{"🔥", "👍"}},
+		{name: "trims whitespace", emojis: []string{" 👍", "❤️ ", "\t🔥\n"}, want: []string{"👍", "❤️", "🔥"}},
+		{name: "nil", emojis: nil, wantErr: true},
+		{name: "empty list", emojis: []string{}, wantErr: true},
+		{name: "empty entry", emojis: []string{"👍", ""}, wantErr: true},
+		{name: "blank entry", emojis: []string{"👍", "  "}, wantErr: true},
+		{name: "duplicate", emojis: []string{"👍", "🔥", "👍"}, wantErr: true},
+		{name: "duplicate after trimming", emojis: []string{"👍", " 👍 "}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalogue, err := NewCatalogue(tt.emojis)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCatalogue error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := catalogue.Emojis(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Emojis = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogueAllows(t *testing.T) {
+	catalogue, err := NewCatalogue([]string{" 👍", "🔥"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		emoji string
+		want  bool
+	}{
+		{"👍", true},
+		{"🔥", true},
+		{" 👍", false},
+		{"😂", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := catalogue.Allows(tt.emoji); got != tt.want {
+			t.Errorf("Allows(%q) = %v, want %v", tt.emoji, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogueEmojisReturnsCopy(t *testing.T) {
+	catalogue, err := NewCatalogue([]string{"👍", "🔥"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalogue.Emojis()[0] = "😂"
+	if got := catalogue.Emojis()[0]; got != "👍" {
+		t.Errorf("Emojis()[0] = %q after mutating a copy, want 👍", got)
+	}
+}
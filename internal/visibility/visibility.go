@@ -0,0 +1,105 @@
+package visibility
+
+import (
+	"context"
+	"fmt"
+
+	"stories-service/internal/db"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Public  = "public"
+	Friends = "friends"
+	Mutuals = "mutuals"
+	Private = "private"
+)
+
+// Predicate returns a SQL condition that is true when the viewer bound to
+// viewerParam may see the story aliased as alias. A block in either direction
+// hides everything; public stories from private accounts need an accepted
+// follow; explicit audience lists on friends stories take precedence over the
+// follow graph; mutuals stories need accepted follows in both directions.
+// Expiry and deletion are left to the caller.
+func Predicate(alias, viewerParam string) string {
+	return fmt.Sprintf(`(
+		%[1]s.author_id = %[2]s
+		OR (
+			NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.blocker_id = %[1]s.author_id AND b.blocked_id = %[2]s)
+				   OR (b.blocker_id = %[2]s AND b.blocked_id = %[1]s.author_id)
+			)
+			AND (
+				(%[1]s.visibility = 'public' AND (
+					NOT EXISTS (SELECT 1 FROM users u WHERE u.id = %[1]s.author_id AND u.is_private)
+					OR %[3]s
+				))
+				OR (%[1]s.visibility = 'friends' AND CASE
+					WHEN EXISTS (SELECT 1 FROM story_audience sa WHERE sa.story_id = %[1]s.id)
+					THEN EXISTS (SELECT 1 FROM story_audience sa WHERE sa.story_id = %[1]s.id AND sa.user_id = %[2]s)
+					ELSE %[3]s
+				END)
+				OR (%[1]s.visibility = 'mutuals' AND %[3]s AND %[4]s)
+			)
+		)
+	)`, alias, viewerParam, follows(viewerParam, alias+".author_id"), follows(alias+".author_id", viewerParam))
+}
+
+// follows returns a SQL condition that is true when follower has an accepted
+// follow on followee. Pending follow requests grant nothing.
+func follows(follower, followee string) string {
+	return fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = %s AND f.followee_id = %s AND f.status = 'accepted')",
+		follower, followee)
+}
+
+// FeedPredicate is Predicate plus the viewer's mutes, which hide authors from
+// the feed without affecting direct access to their stories.
+func FeedPredicate(alias, viewerParam string) string {
+	return fmt.Sprintf(`(
+		%[3]s
+		AND NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = %[2]s AND m.muted_id = %[1]s.author_id)
+	)`, alias, viewerParam, Predicate(alias, viewerParam))
+}
+
+type Evaluator struct {
+	db *db.DB
+}
+
+func NewEvaluator(database *db.DB) *Evaluator {
+	return &Evaluator{db: database}
+}
+
+// CanView reports whether viewerID may see the story, regardless of
+// whether it has expired or been deleted.
+func (e *Evaluator) CanView(ctx context.Context, viewerID, storyID uuid.UUID) (bool, error) {
+	var canView bool
+	err := e.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM stories s WHERE s.id = $2 AND `+Predicate("s", "$1")+`)
+	`, viewerID, storyID).Scan(&canView)
+	return canView, err
+}
+
+// CanViewAuthor reports whether viewerID may see the author's public
+// stories: neither has blocked the other, and the author's account is public
+// or the viewer is an accepted follower.
+func (e *Evaluator) CanViewAuthor(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	var canView bool
+	err := e.db.QueryRowContext(ctx, `
+		SELECT $1::uuid = $2::uuid OR (
+			EXISTS (SELECT 1 FROM users u WHERE u.id = $2)
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks b
+				WHERE (b.blocker_id = $2 AND b.blocked_id = $1)
+				   OR (b.blocker_id = $1 AND b.blocked_id = $2)
+			)
+			AND (
+				NOT EXISTS (SELECT 1 FROM users u WHERE u.id = $2 AND u.is_private)
+				OR `+follows("$1", "$2")+`
+			)
+		)
+	`, viewerID, authorID).Scan(&canView)
+	return canView, err
+}
@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Broker carries events between hubs. A hub subscribes to the channel of
+// every user with a socket connected to it and publishes every event through
+// the broker, so the event reaches the user's sockets on whichever replica
+// holds them.
+type Broker interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	Subscribe(ctx context.Context, channel string) error
+	Unsubscribe(ctx context.Context, channel string) error
+	// Messages delivers everything published to a subscribed channel.
+	Messages() <-chan *Message
+	Close() error
+}
+
+func userChannel(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func parseUserChannel(channel string) (uuid.UUID, bool) {
+	raw, ok := strings.CutPrefix(channel, "user:")
+	if !ok {
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(raw)
+	return userID, err == nil
+}
+
+func topicChannel(topic string) string {
+	return "topic:" + topic
+}
+
+func parseTopicChannel(channel string) (string, bool) {
+	return strings.CutPrefix(channel, "topic:")
+}
+
+// LocalBroker delivers events within the process only. It is used when the
+// API runs as a single replica or without Redis.
+type LocalBroker struct {
+	messages chan *Message
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{messages: make(chan *Message, 256)}
+}
+
+func (b *LocalBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	select {
+	case b.messages <- &Message{Channel: channel, Payload: payload}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe is a no-op: the hub drops messages for users it has no sockets for.
+func (b *LocalBroker) Subscribe(ctx context.Context, channel string) error {
+	return nil
+}
+
+func (b *LocalBroker) Unsubscribe(ctx context.Context, channel string) error {
+	return nil
+}
+
+func (b *LocalBroker) Messages() <-chan *Message {
+	return b.messages
+}
+
+func (b *LocalBroker) Close() error {
+	return nil
+}
+
+// RedisBroker fans events out to every replica through Redis pub/sub. Redis
+// channels are prefixed with "ws:" to keep them apart from other users of the
+// same server. Pub/sub is fire-and-forget: events published while a replica
+// is disconnected from Redis are not redelivered.
+type RedisBroker struct {
+	client   *redis.Client
+	pubsub   *redis.PubSub
+	messages chan *Message
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	b := &RedisBroker{
+		client:   client,
+		pubsub:   client.Subscribe(context.Background()),
+		messages: make(chan *Message, 256),
+	}
+	go b.forward()
+	return b
+}
+
+func (b *RedisBroker) forward() {
+	defer close(b.messages)
+	for msg := range b.pubsub.Channel() {
+		b.messages <- &Message{
+			Channel: strings.TrimPrefix(msg.Channel, "ws:"),
+			Payload: []byte(msg.Payload),
+		}
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	return b.client.Publish(ctx, "ws:"+channel, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, channel string) error {
+	return b.pubsub.Subscribe(ctx, "ws:"+channel)
+}
+
+func (b *RedisBroker) Unsubscribe(ctx context.Context, channel string) error {
+	return b.pubsub.Unsubscribe(ctx, "ws:"+channel)
+}
+
+func (b *RedisBroker) Messages() <-chan *Message {
+	return b.messages
+}
+
+func (b *RedisBroker) Close() error {
+	return b.pubsub.Close()
+}
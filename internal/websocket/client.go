@@ -11,22 +11,63 @@ const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 4096
 )
 
 type Client struct {
-	UserID uuid.UUID
-	hub    *Hub
-	conn   *websocket.Conn
-	send   chan []byte
+	UserID     uuid.UUID
+	hub        *Hub
+	conn       *websocket.Conn
+	connID     string
+	send       chan *Message
+	replies    chan []byte
+	registered chan struct{}
+	topics     map[string]bool
+	watching   map[uuid.UUID]bool
+	backlog    []*Message
+	replayedTo int64
 }
 
 func NewClient(userID uuid.UUID, hub *Hub, conn *websocket.Conn) *Client {
 	return &Client{
-		UserID: userID,
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
+		UserID:     userID,
+		hub:        hub,
+		conn:       conn,
+		send:       make(chan *Message, 256),
+		replies:    make(chan []byte, 16),
+		connID:     uuid.NewString(),
+		registered: make(chan struct{}),
+		topics:     make(map[string]bool),
+		watching:   make(map[uuid.UUID]bool),
+	}
+}
+
+// NewStreamClient creates a client with no socket, for transports such as
+// Server-Sent Events that write events themselves. After registering, read
+// events from Events; the channel is closed when the hub drops the client.
+func NewStreamClient(userID uuid.UUID, hub *Hub) *Client {
+	return NewClient(userID, hub, nil)
+}
+
+// watcher identifies this connection in a story's shared watcher set.
+func (c *Client) watcher() Watcher {
+	return Watcher{UserID: c.UserID, ConnID: c.connID}
+}
+
+func (c *Client) Events() <-chan *Message {
+	return c.send
+}
+
+// Replay queues stored events to be written ahead of live ones. It must be
+// called after RegisterClient and before WritePump. Live events numbered up
+// to through, or part of the replay, are not written again.
+func (c *Client) Replay(messages []*Message, through int64) {
+	c.backlog = messages
+	c.replayedTo = through
+	for _, message := range messages {
+		if message.Seq > c.replayedTo {
+			c.replayedTo = message.Seq
+		}
 	}
 }
 
@@ -36,6 +77,7 @@ func (c *Client) ReadPump() {
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -43,10 +85,15 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		messageType, data, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		if messageType != websocket.TextMessage {
+			c.reply(Frame{Type: "error", Code: ErrInvalidFrame, Message: "frames must be sent as text"})
+			continue
+		}
+		c.handleFrame(data)
 	}
 }
 
@@ -57,6 +104,14 @@ func (c *Client) WritePump() {
 		c.conn.Close()
 	}()
 
+	for _, message := range c.backlog {
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, message.Payload); err != nil {
+			return
+		}
+	}
+	c.backlog = nil
+
 	for {
 		select {
 		case message, ok := <-c.send:
@@ -66,7 +121,17 @@ func (c *Client) WritePump() {
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if message.Seq != 0 && message.Seq <= c.replayedTo {
+				continue
+			}
+
+			if err := c.conn.WriteMessage(websocket.TextMessage, message.Payload); err != nil {
+				return
+			}
+
+		case reply := <-c.replies:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, reply); err != nil {
 				return
 			}
 
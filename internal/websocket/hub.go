@@ -1,13 +1,21 @@
 package websocket
 
 import (
+        "context"
         "encoding/json"
         "sync"
 
         "github.com/google/uuid"
+        "go.uber.org/zap"
 )
 
+// Event is the envelope written to sockets. Seq is the event's position in
+// the recipient's inbox; it is omitted when the hub has no inbox, the event
+// could not be stored, or it was sent to a topic. Topic is set on events sent
+// to a topic the socket subscribed to.
 type Event struct {
+        Seq     int64       `json:"seq,omitempty"`
+        Topic   string      `json:"topic,omitempty"`
         Type    string      `json:"type"`
         Payload interface{} `json:"payload"`
 }
@@ -24,29 +32,106 @@ type ReactionEvent struct {
         Emoji   string    `json:"emoji"`
 }
 
+type StoryDeletedEvent struct {
+        StoryID  uuid.UUID `json:"story_id"`
+        AuthorID uuid.UUID `json:"author_id"`
+}
+
+type StoryCreatedEvent struct {
+        StoryID   uuid.UUID `json:"story_id"`
+        AuthorID  uuid.UUID `json:"author_id"`
+        CreatedAt string    `json:"created_at"`
+}
+
+type StoryUpdatedEvent struct {
+        StoryID  uuid.UUID `json:"story_id"`
+        AuthorID uuid.UUID `json:"author_id"`
+        EditedAt string    `json:"edited_at"`
+}
+
+type FollowEvent struct {
+        FollowerID uuid.UUID `json:"follower_id"`
+        FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+type StoryRepliedEvent struct {
+        ThreadID  uuid.UUID `json:"thread_id"`
+        StoryID   uuid.UUID `json:"story_id"`
+        MessageID uuid.UUID `json:"message_id"`
+        SenderID  uuid.UUID `json:"sender_id"`
+        Text      string    `json:"text"`
+        CreatedAt string    `json:"created_at"`
+}
+
 type Hub struct {
-        clients    map[uuid.UUID]map[*Client]bool
-        broadcast  chan *Message
-        register   chan *Client
-        unregister chan *Client
-        mu         sync.RWMutex
+        clients       map[uuid.UUID]map[*Client]bool
+        topics        map[string]map[*Client]bool
+        broker        Broker
+        inbox         Inbox
+        pending       chan pendingEvent
+        authorizer    Authorizer
+        presence      Presence
+        watched       map[uuid.UUID]*watchedStory
+        register      chan *Client
+        unregister    chan *Client
+        topicRequests chan topicRequest
+        logger        *zap.Logger
+        mu            sync.RWMutex
+}
+
+type topicRequest struct {
+        client    *Client
+        topic     string
+        subscribe bool
+        result    chan bool
 }
 
 type Message struct {
-        UserID  uuid.UUID
+        Channel string
+        Seq     int64
         Payload []byte
 }
 
-func NewHub() *Hub {
+// NewHub creates a hub that routes events through broker. With a
+// LocalBroker events only reach sockets on this process; with a shared
+// broker such as RedisBroker they reach sockets on every replica. Events are
+// stored in inbox, in batches off the request path, before they are
+// published; a nil inbox disables storage.
+// Topic subscriptions are checked with authorizer; a nil authorizer disables
+// them. Watched stories are tracked in presence; a nil presence disables it.
+func NewHub(broker Broker, inbox Inbox, authorizer Authorizer, presence Presence, logger *zap.Logger) *Hub {
+        var pending chan pendingEvent
+        if inbox != nil {
+                pending = make(chan pendingEvent, inboxQueueSize)
+        }
         return &Hub{
-                clients:    make(map[uuid.UUID]map[*Client]bool),
-                broadcast:  make(chan *Message, 256),
-                register:   make(chan *Client),
-                unregister: make(chan *Client),
+                clients:       make(map[uuid.UUID]map[*Client]bool),
+                topics:        make(map[string]map[*Client]bool),
+                broker:        broker,
+                inbox:         inbox,
+                pending:       pending,
+                authorizer:    authorizer,
+                presence:      presence,
+                watched:       make(map[uuid.UUID]*watchedStory),
+                register:      make(chan *Client),
+                unregister:    make(chan *Client),
+                topicRequests: make(chan topicRequest),
+                logger:        logger,
         }
 }
 
 func (h *Hub) Run() {
+        if h.inbox != nil {
+                go h.persistEvents()
+        }
+        if h.presence != nil {
+                go h.refreshPresence()
+        }
+        if h.authorizer != nil {
+                h.subscribeChannel(recheckChannel)
+        }
+
+        messages := h.broker.Messages()
         for {
                 select {
                 case client := <-h.register:
@@ -55,50 +140,272 @@ func (h *Hub) Run() {
                                 h.clients[client.UserID] = make(map[*Client]bool)
                         }
                         h.clients[client.UserID][client] = true
+                        first := len(h.clients[client.UserID]) == 1
                         h.mu.Unlock()
 
+                        if first {
+                                h.subscribe(client.UserID)
+                        }
+                        close(client.registered)
+
                 case client := <-h.unregister:
                         h.mu.Lock()
+                        last := false
+                        var emptied []string
                         if clients, ok := h.clients[client.UserID]; ok {
                                 if _, ok := clients[client]; ok {
                                         delete(clients, client)
                                         close(client.send)
                                         if len(clients) == 0 {
                                                 delete(h.clients, client.UserID)
+                                                last = true
+                                        }
+                                        for topic := range client.topics {
+                                                if h.removeFromTopic(client, topic) {
+                                                        emptied = append(emptied, topic)
+                                                }
+                                        }
+                                        for storyID := range client.watching {
+                                                if authorID, ok := h.removeWatch(client, storyID); ok {
+                                                        go h.leaveStory(context.Background(), storyID, authorID, client.watcher())
+                                                }
                                         }
                                 }
                         }
                         h.mu.Unlock()
 
-                case message := <-h.broadcast:
-                        h.mu.RLock()
-                        if clients, ok := h.clients[message.UserID]; ok {
-                                for client := range clients {
-                                        select {
-                                        case client.send <- message.Payload:
-                                        default:
-                                                close(client.send)
-                                                delete(clients, client)
-                                        }
-                                }
+                        if last {
+                                h.unsubscribe(client.UserID)
                         }
-                        h.mu.RUnlock()
+                        for _, topic := range emptied {
+                                h.unsubscribeChannel(topicChannel(topic))
+                        }
+
+                case req := <-h.topicRequests:
+                        req.result <- h.handleTopicRequest(req)
+
+                case message, ok := <-messages:
+                        if !ok {
+                                h.logger.Error("hub broker closed, no longer receiving events")
+                                messages = nil
+                                continue
+                        }
+                        h.deliver(message)
+                }
+        }
+}
+
+func (h *Hub) subscribe(userID uuid.UUID) {
+        h.subscribeChannel(userChannel(userID))
+}
+
+func (h *Hub) unsubscribe(userID uuid.UUID) {
+        h.unsubscribeChannel(userChannel(userID))
+}
+
+func (h *Hub) subscribeChannel(channel string) {
+        if err := h.broker.Subscribe(context.Background(), channel); err != nil {
+                h.logger.Error("failed to subscribe to events",
+                        zap.String("channel", channel), zap.Error(err))
+        }
+}
+
+func (h *Hub) unsubscribeChannel(channel string) {
+        if err := h.broker.Unsubscribe(context.Background(), channel); err != nil {
+                h.logger.Error("failed to unsubscribe from events",
+                        zap.String("channel", channel), zap.Error(err))
+        }
+}
+
+// handleTopicRequest adds or removes a client's topic subscription, and
+// subscribes the broker on a topic's first local follower and unsubscribes
+// it after the last. It runs on Run's goroutine so broker calls for a topic
+// happen in order. Subscribing fails only when the client is at its limit.
+func (h *Hub) handleTopicRequest(req topicRequest) bool {
+        h.mu.Lock()
+        client := req.client
+        if _, ok := h.clients[client.UserID][client]; !ok {
+                // Already unregistered; the socket is closing.
+                h.mu.Unlock()
+                return true
+        }
+
+        if !req.subscribe {
+                emptied := client.topics[req.topic] && h.removeFromTopic(client, req.topic)
+                h.mu.Unlock()
+                if emptied {
+                        h.unsubscribeChannel(topicChannel(req.topic))
+                }
+                return true
+        }
+
+        if client.topics[req.topic] {
+                h.mu.Unlock()
+                return true
+        }
+        if len(client.topics) >= maxTopicsPerClient {
+                h.mu.Unlock()
+                return false
+        }
+        client.topics[req.topic] = true
+        if _, ok := h.topics[req.topic]; !ok {
+                h.topics[req.topic] = make(map[*Client]bool)
+        }
+        h.topics[req.topic][client] = true
+        first := len(h.topics[req.topic]) == 1
+        h.mu.Unlock()
+
+        if first {
+                h.subscribeChannel(topicChannel(req.topic))
+        }
+        return true
+}
+
+// removeFromTopic drops a client from a topic and reports whether it was the
+// topic's last local follower. The caller holds h.mu.
+func (h *Hub) removeFromTopic(client *Client, topic string) bool {
+        delete(client.topics, topic)
+        clients, ok := h.topics[topic]
+        if !ok {
+                return false
+        }
+        delete(clients, client)
+        if len(clients) > 0 {
+                return false
+        }
+        delete(h.topics, topic)
+        return true
+}
+
+func (h *Hub) subscribeTopic(client *Client, topic string) bool {
+        result := make(chan bool, 1)
+        h.topicRequests <- topicRequest{client: client, topic: topic, subscribe: true, result: result}
+        return <-result
+}
+
+func (h *Hub) unsubscribeTopic(client *Client, topic string) {
+        result := make(chan bool, 1)
+        h.topicRequests <- topicRequest{client: client, topic: topic, result: result}
+        <-result
+}
+
+// deliver writes a message to the sockets on this process that follow its
+// channel: a user's sockets or a topic's subscribers. Sockets whose send
+// buffer is full are unregistered through Run, which also drops the
+// subscriptions nobody on this process needs any more.
+// Recheck requests are handled off Run's goroutine, since they query the
+// authorizer and then go back through Run to drop subscriptions.
+func (h *Hub) deliver(message *Message) {
+        if message.Channel == recheckChannel {
+                go h.recheck(message.Payload)
+                return
+        }
+
+        userID, isUser := parseUserChannel(message.Channel)
+        topic, isTopic := parseTopicChannel(message.Channel)
+        if !isUser && !isTopic {
+                return
+        }
+
+        if message.Seq == 0 {
+                var envelope struct {
+                        Seq int64 `json:"seq"`
+                }
+                if json.Unmarshal(message.Payload, &envelope) == nil {
+                        message.Seq = envelope.Seq
+                }
+        }
+
+        h.mu.RLock()
+        defer h.mu.RUnlock()
+
+        clients := h.topics[topic]
+        if isUser {
+                clients = h.clients[userID]
+        }
+        for client := range clients {
+                select {
+                case client.send <- message:
+                default:
+                        go func(client *Client) { h.unregister <- client }(client)
                 }
         }
 }
 
+// RegisterClient adds a socket to the hub and returns once the hub is
+// subscribed to the user's events, so any event stored after it returns is
+// also delivered live.
 func (h *Hub) RegisterClient(client *Client) {
         h.register <- client
+        <-client.registered
+}
+
+// UnregisterClient removes a socket whose pumps were never started, or a
+// stream client whose reader has gone.
+func (h *Hub) UnregisterClient(client *Client) {
+        h.unregister <- client
 }
 
+// SendToUser stores an event in the user's inbox and publishes it to every
+// socket the user has open, on any replica. It does not wait for either: the
+// event is queued and stored with others in one write, then published. If
+// the broker is unreachable the event still reaches sockets on this process;
+// if the inbox is unavailable or its queue is full it is delivered live
+// without a sequence number.
 func (h *Hub) SendToUser(userID uuid.UUID, event Event) {
+        event.Seq = 0
+        if h.inbox != nil {
+                if h.queueForInbox(userID, event) {
+                        return
+                }
+                h.logger.Warn("inbox queue full, delivering event without sequence number",
+                        zap.String("user_id", userID.String()), zap.String("type", event.Type))
+        }
+        h.publishToUser(userID, event)
+}
+
+// publishToUser publishes an event to every socket the user has open.
+func (h *Hub) publishToUser(userID uuid.UUID, event Event) {
+        payload, err := json.Marshal(event)
+        if err != nil {
+                return
+        }
+
+        h.publish(&Message{Channel: userChannel(userID), Seq: event.Seq, Payload: payload})
+}
+
+// SendEphemeral publishes an event to every socket the user has open without
+// storing it, for short-lived state such as presence that is not worth
+// replaying.
+func (h *Hub) SendEphemeral(userID uuid.UUID, event Event) {
+        event.Seq = 0
+        payload, err := json.Marshal(event)
+        if err != nil {
+                return
+        }
+
+        h.publish(&Message{Channel: userChannel(userID), Payload: payload})
+}
+
+// SendToTopic publishes an event to every socket subscribed to topic, on any
+// replica. Topic events are not stored, so they are not replayed on
+// reconnect.
+func (h *Hub) SendToTopic(topic string, event Event) {
+        event.Seq = 0
+        event.Topic = topic
         payload, err := json.Marshal(event)
         if err != nil {
                 return
         }
 
-        h.broadcast <- &Message{
-                UserID:  userID,
-                Payload: payload,
+        h.publish(&Message{Channel: topicChannel(topic), Payload: payload})
+}
+
+// publish hands a message to the broker. If the broker is unreachable the
+// message still reaches sockets on this process.
+func (h *Hub) publish(message *Message) {
+        if err := h.broker.Publish(context.Background(), message.Channel, message.Payload); err != nil {
+                h.logger.Warn("failed to publish event, delivering locally only", zap.Error(err))
+                h.deliver(message)
         }
 }
@@ -0,0 +1,553 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// memoryBus stands in for Redis: every broker attached to it receives the
+// messages published on channels it has subscribed to.
+type memoryBus struct {
+	mu      sync.Mutex
+	brokers []*memoryBroker
+}
+
+func (bus *memoryBus) broker() *memoryBroker {
+	b := &memoryBroker{
+		bus:      bus,
+		channels: make(map[string]bool),
+		messages: make(chan *Message, 16),
+	}
+	bus.mu.Lock()
+	bus.brokers = append(bus.brokers, b)
+	bus.mu.Unlock()
+	return b
+}
+
+type memoryBroker struct {
+	bus      *memoryBus
+	mu       sync.Mutex
+	channels map[string]bool
+	messages chan *Message
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	b.bus.mu.Lock()
+	defer b.bus.mu.Unlock()
+	for _, broker := range b.bus.brokers {
+		if broker.subscribed(channel) {
+			broker.messages <- &Message{Channel: channel, Payload: payload}
+		}
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context, channel string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.channels[channel] = true
+	return nil
+}
+
+func (b *memoryBroker) Unsubscribe(ctx context.Context, channel string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.channels, channel)
+	return nil
+}
+
+func (b *memoryBroker) Messages() <-chan *Message {
+	return b.messages
+}
+
+func (b *memoryBroker) Close() error {
+	return nil
+}
+
+func (b *memoryBroker) subscribed(channel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.channels[channel]
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestClient(userID uuid.UUID, hub *Hub) *Client {
+	return &Client{
+		UserID:     userID,
+		hub:        hub,
+		send:       make(chan *Message, 1),
+		replies:    make(chan []byte, 1),
+		connID:     uuid.NewString(),
+		registered: make(chan struct{}),
+		topics:     make(map[string]bool),
+		watching:   make(map[uuid.UUID]bool),
+	}
+}
+
+func TestSendToUserReachesClientOnAnotherHub(t *testing.T) {
+	bus := &memoryBus{}
+	brokerA, brokerB := bus.broker(), bus.broker()
+	hubA := NewHub(brokerA, nil, nil, nil, zap.NewNop())
+	hubB := NewHub(brokerB, nil, nil, nil, zap.NewNop())
+	go hubA.Run()
+	go hubB.Run()
+
+	userID := uuid.New()
+	client := newTestClient(userID, hubA)
+	hubA.RegisterClient(client)
+	waitFor(t, "hub A to subscribe", func() bool { return brokerA.subscribed(userChannel(userID)) })
+
+	storyID := uuid.New()
+	hubB.SendToUser(userID, Event{
+		Type:    "story.viewed",
+		Payload: ViewEvent{StoryID: storyID, ViewerID: uuid.New()},
+	})
+
+	select {
+	case message := <-client.send:
+		var event struct {
+			Type    string    `json:"type"`
+			Payload ViewEvent `json:"payload"`
+		}
+		if err := json.Unmarshal(message.Payload, &event); err != nil {
+			t.Fatalf("invalid payload: %v", err)
+		}
+		if event.Type != "story.viewed" || event.Payload.StoryID != storyID {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered across hubs")
+	}
+
+	if brokerB.subscribed(userChannel(userID)) {
+		t.Fatal("hub B subscribed to a user with no local sockets")
+	}
+}
+
+func TestLastClientUnsubscribes(t *testing.T) {
+	bus := &memoryBus{}
+	broker := bus.broker()
+	hub := NewHub(broker, nil, nil, nil, zap.NewNop())
+	go hub.Run()
+
+	userID := uuid.New()
+	first := newTestClient(userID, hub)
+	second := newTestClient(userID, hub)
+	hub.RegisterClient(first)
+	hub.RegisterClient(second)
+	waitFor(t, "subscription", func() bool { return broker.subscribed(userChannel(userID)) })
+
+	hub.unregister <- first
+	// Run handles one request at a time, so once this registration is
+	// accepted the unregistration above has been fully processed.
+	hub.RegisterClient(newTestClient(uuid.New(), hub))
+	if !broker.subscribed(userChannel(userID)) {
+		t.Fatal("unsubscribed while the user still had a socket open")
+	}
+
+	hub.unregister <- second
+	waitFor(t, "unsubscription", func() bool { return !broker.subscribed(userChannel(userID)) })
+}
+
+type countingInbox struct {
+	mu   sync.Mutex
+	seqs map[uuid.UUID]int64
+}
+
+func (i *countingInbox) Append(ctx context.Context, entries []InboxEntry) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for j := range entries {
+		i.seqs[entries[j].UserID]++
+		entries[j].Seq = i.seqs[entries[j].UserID]
+	}
+	return nil
+}
+
+func (i *countingInbox) MarkRead(ctx context.Context, userID uuid.UUID, upToSeq int64) (int64, error) {
+	return 0, nil
+}
+
+func TestSendToUserNumbersEventsFromInbox(t *testing.T) {
+	bus := &memoryBus{}
+	hub := NewHub(bus.broker(), &countingInbox{seqs: make(map[uuid.UUID]int64)}, nil, nil, zap.NewNop())
+	go hub.Run()
+
+	userID := uuid.New()
+	client := newTestClient(userID, hub)
+	client.send = make(chan *Message, 2)
+	hub.RegisterClient(client)
+
+	for i := 0; i < 2; i++ {
+		hub.SendToUser(userID, Event{Type: "user.followed", Payload: FollowEvent{FollowerID: uuid.New(), FolloweeID: userID}})
+	}
+
+	for want := int64(1); want <= 2; want++ {
+		select {
+		case message := <-client.send:
+			var event Event
+			if err := json.Unmarshal(message.Payload, &event); err != nil {
+				t.Fatalf("invalid payload: %v", err)
+			}
+			if message.Seq != want || event.Seq != want {
+				t.Fatalf("got seq %d (envelope %d), want %d", message.Seq, event.Seq, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d was not delivered", want)
+		}
+	}
+}
+
+// storyAuthorizer grants access to a single story and no authors.
+type storyAuthorizer struct {
+	storyID uuid.UUID
+}
+
+func (a storyAuthorizer) CanView(ctx context.Context, viewerID, storyID uuid.UUID) (bool, error) {
+	return storyID == a.storyID, nil
+}
+
+func (a storyAuthorizer) CanViewAuthor(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+func readReply(t *testing.T, client *Client) Frame {
+	t.Helper()
+	select {
+	case payload := <-client.replies:
+		var frame Frame
+		if err := json.Unmarshal(payload, &frame); err != nil {
+			t.Fatalf("invalid reply: %v", err)
+		}
+		return frame
+	case <-time.After(time.Second):
+		t.Fatal("no reply")
+		return Frame{}
+	}
+}
+
+func TestTopicSubscriptionRoutesAcrossHubs(t *testing.T) {
+	bus := &memoryBus{}
+	storyID := uuid.New()
+	brokerA := bus.broker()
+	hubA := NewHub(brokerA, nil, storyAuthorizer{storyID: storyID}, nil, zap.NewNop())
+	hubB := NewHub(bus.broker(), nil, nil, nil, zap.NewNop())
+	go hubA.Run()
+	go hubB.Run()
+
+	client := newTestClient(uuid.New(), hubA)
+	hubA.RegisterClient(client)
+
+	client.handleFrame([]byte(`{"type":"subscribe","id":"1","topic":"author:` + uuid.NewString() + `"}`))
+	if reply := readReply(t, client); reply.Type != "error" || reply.Code != ErrForbidden || reply.ID != "1" {
+		t.Fatalf("expected forbidden error, got %+v", reply)
+	}
+
+	topic := StoryTopic(storyID)
+	client.handleFrame([]byte(`{"type":"subscribe","id":"2","topic":"` + topic + `"}`))
+	if reply := readReply(t, client); reply.Type != "subscribed" || reply.Topic != topic {
+		t.Fatalf("expected subscribed, got %+v", reply)
+	}
+
+	hubB.SendToTopic(topic, Event{Type: "story.deleted", Payload: StoryDeletedEvent{StoryID: storyID}})
+	select {
+	case message := <-client.send:
+		var event Event
+		if err := json.Unmarshal(message.Payload, &event); err != nil {
+			t.Fatalf("invalid payload: %v", err)
+		}
+		if event.Topic != topic || event.Type != "story.deleted" || event.Seq != 0 {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("topic event was not delivered")
+	}
+
+	client.handleFrame([]byte(`{"type":"unsubscribe","id":"3","topic":"` + topic + `"}`))
+	if reply := readReply(t, client); reply.Type != "unsubscribed" {
+		t.Fatalf("expected unsubscribed, got %+v", reply)
+	}
+	if brokerA.subscribed(topicChannel(topic)) {
+		t.Fatal("topic still subscribed after its only follower left")
+	}
+}
+
+func TestPingAndUnknownFrames(t *testing.T) {
+	hub := NewHub((&memoryBus{}).broker(), nil, nil, nil, zap.NewNop())
+	client := newTestClient(uuid.New(), hub)
+
+	client.handleFrame([]byte(`{"type":"ping","id":"p","ts":1234}`))
+	if reply := readReply(t, client); reply.Type != "pong" || reply.TS != 1234 || reply.ServerTS == 0 {
+		t.Fatalf("unexpected pong %+v", reply)
+	}
+
+	client.handleFrame([]byte(`not json`))
+	if reply := readReply(t, client); reply.Code != ErrInvalidFrame {
+		t.Fatalf("expected invalid_frame, got %+v", reply)
+	}
+
+	client.handleFrame([]byte(`{"type":"shout"}`))
+	if reply := readReply(t, client); reply.Code != ErrUnknownType {
+		t.Fatalf("expected unknown_type, got %+v", reply)
+	}
+}
+
+// memoryPresence tracks watchers in process; every story not listed in ended
+// is active and written by author. Stories listed in expired are reported
+// once by ExpireWatchers.
+type memoryPresence struct {
+	author   uuid.UUID
+	mu       sync.Mutex
+	watchers map[uuid.UUID]map[Watcher]bool
+	expired  map[uuid.UUID]int64
+	ended    map[uuid.UUID]bool
+}
+
+// users counts the distinct users watching a story. The caller holds p.mu.
+func (p *memoryPresence) users(storyID uuid.UUID) int64 {
+	seen := make(map[uuid.UUID]bool)
+	for w := range p.watchers[storyID] {
+		seen[w.UserID] = true
+	}
+	return int64(len(seen))
+}
+
+func (p *memoryPresence) StoryAuthor(ctx context.Context, storyID uuid.UUID) (uuid.UUID, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.author, !p.ended[storyID], nil
+}
+
+func (p *memoryPresence) Watch(ctx context.Context, storyID uuid.UUID, watchers []Watcher, ttl time.Duration) (int64, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watchers[storyID] == nil {
+		p.watchers[storyID] = make(map[Watcher]bool)
+	}
+	for _, w := range watchers {
+		p.watchers[storyID][w] = true
+	}
+	return p.users(storyID), 0, nil
+}
+
+func (p *memoryPresence) Unwatch(ctx context.Context, storyID uuid.UUID, watcher Watcher) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.watchers[storyID], watcher)
+	return p.users(storyID), nil
+}
+
+func (p *memoryPresence) ExpireWatchers(ctx context.Context) (map[uuid.UUID]int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expired := p.expired
+	p.expired = nil
+	return expired, nil
+}
+
+func readPresence(t *testing.T, client *Client) StoryPresenceEvent {
+	t.Helper()
+	select {
+	case message := <-client.send:
+		var event struct {
+			Seq     int64              `json:"seq"`
+			Type    string             `json:"type"`
+			Payload StoryPresenceEvent `json:"payload"`
+		}
+		if err := json.Unmarshal(message.Payload, &event); err != nil {
+			t.Fatalf("invalid payload: %v", err)
+		}
+		if event.Type != "story.presence" || event.Seq != 0 {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		return event.Payload
+	case <-time.After(time.Second):
+		t.Fatal("no presence event")
+		return StoryPresenceEvent{}
+	}
+}
+
+func TestWatchReportsPresenceToAuthor(t *testing.T) {
+	storyID, authorID := uuid.New(), uuid.New()
+	inbox := &countingInbox{seqs: make(map[uuid.UUID]int64)}
+	presence := &memoryPresence{author: authorID, watchers: make(map[uuid.UUID]map[Watcher]bool)}
+	hub := NewHub((&memoryBus{}).broker(), inbox, storyAuthorizer{storyID: storyID}, presence, zap.NewNop())
+	go hub.Run()
+
+	author := newTestClient(authorID, hub)
+	viewer := newTestClient(uuid.New(), hub)
+	hub.RegisterClient(author)
+	hub.RegisterClient(viewer)
+
+	viewer.handleFrame([]byte(`{"type":"watch","id":"w","story_id":"` + storyID.String() + `"}`))
+	if reply := readReply(t, viewer); reply.Type != "watching" || reply.StoryID != storyID.String() {
+		t.Fatalf("expected watching, got %+v", reply)
+	}
+	if event := readPresence(t, author); event.StoryID != storyID || event.Watching != 1 {
+		t.Fatalf("unexpected presence %+v", event)
+	}
+
+	// The same user in a second tab is counted once, and closing either tab
+	// leaves them watching.
+	secondTab := newTestClient(viewer.UserID, hub)
+	hub.RegisterClient(secondTab)
+	secondTab.handleFrame([]byte(`{"type":"watch","id":"w","story_id":"` + storyID.String() + `"}`))
+	readReply(t, secondTab)
+	if event := readPresence(t, author); event.Watching != 1 {
+		t.Fatalf("expected one watcher across tabs, got %+v", event)
+	}
+	secondTab.handleFrame([]byte(`{"type":"unwatch","id":"u","story_id":"` + storyID.String() + `"}`))
+	readReply(t, secondTab)
+	if event := readPresence(t, author); event.Watching != 1 {
+		t.Fatalf("expected the first tab to keep watching, got %+v", event)
+	}
+
+	// A dropped socket leaves the story without an unwatch frame.
+	hub.UnregisterClient(viewer)
+	if event := readPresence(t, author); event.Watching != 0 {
+		t.Fatalf("expected nobody watching, got %+v", event)
+	}
+
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	if inbox.seqs[authorID] != 0 {
+		t.Fatal("presence events were stored in the inbox")
+	}
+}
+
+// switchAuthorizer grants access to every topic until it is revoked.
+type switchAuthorizer struct {
+	mu      sync.Mutex
+	revoked bool
+}
+
+func (a *switchAuthorizer) CanView(ctx context.Context, viewerID, storyID uuid.UUID) (bool, error) {
+	return a.CanViewAuthor(ctx, viewerID, storyID)
+}
+
+func (a *switchAuthorizer) CanViewAuthor(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return !a.revoked, nil
+}
+
+func TestRecheckDropsRevokedSubscriptionsOnOtherHubs(t *testing.T) {
+	bus := &memoryBus{}
+	authorizer := &switchAuthorizer{}
+	brokerA := bus.broker()
+	hubA := NewHub(brokerA, nil, authorizer, nil, zap.NewNop())
+	hubB := NewHub(bus.broker(), nil, authorizer, nil, zap.NewNop())
+	go hubA.Run()
+	go hubB.Run()
+	waitFor(t, "recheck subscription", func() bool { return brokerA.subscribed(recheckChannel) })
+
+	client := newTestClient(uuid.New(), hubA)
+	hubA.RegisterClient(client)
+
+	topic := AuthorTopic(uuid.New())
+	client.handleFrame([]byte(`{"type":"subscribe","id":"1","topic":"` + topic + `"}`))
+	if reply := readReply(t, client); reply.Type != "subscribed" {
+		t.Fatalf("expected subscribed, got %+v", reply)
+	}
+
+	// Still allowed: nothing changes.
+	hubB.RecheckTopic(topic)
+	select {
+	case payload := <-client.replies:
+		t.Fatalf("unexpected reply %s", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	authorizer.mu.Lock()
+	authorizer.revoked = true
+	authorizer.mu.Unlock()
+
+	hubB.RecheckUser(client.UserID)
+	if reply := readReply(t, client); reply.Type != "unsubscribed" || reply.Topic != topic || reply.Code != ErrForbidden {
+		t.Fatalf("expected forbidden unsubscribed, got %+v", reply)
+	}
+	waitFor(t, "topic unsubscription", func() bool { return !brokerA.subscribed(topicChannel(topic)) })
+}
+
+func TestExpiredWatchersReportedWithoutLocalWatchers(t *testing.T) {
+	storyID, authorID := uuid.New(), uuid.New()
+	presence := &memoryPresence{
+		author:   authorID,
+		watchers: make(map[uuid.UUID]map[Watcher]bool),
+		expired:  map[uuid.UUID]int64{storyID: 2},
+	}
+	hub := NewHub((&memoryBus{}).broker(), nil, nil, presence, zap.NewNop())
+	go hub.Run()
+
+	author := newTestClient(authorID, hub)
+	hub.RegisterClient(author)
+
+	hub.expireWatchers()
+	if event := readPresence(t, author); event.StoryID != storyID || event.Watching != 2 {
+		t.Fatalf("unexpected presence %+v", event)
+	}
+}
+
+func TestRecheckAndRefreshDropWatchesThatLostAccess(t *testing.T) {
+	revokedID, endedID, authorID := uuid.New(), uuid.New(), uuid.New()
+	authorizer := &switchAuthorizer{}
+	presence := &memoryPresence{author: authorID, watchers: make(map[uuid.UUID]map[Watcher]bool)}
+	hub := NewHub((&memoryBus{}).broker(), nil, authorizer, presence, zap.NewNop())
+	go hub.Run()
+
+	author := newTestClient(authorID, hub)
+	viewer := newTestClient(uuid.New(), hub)
+	hub.RegisterClient(author)
+	hub.RegisterClient(viewer)
+
+	for _, storyID := range []uuid.UUID{revokedID, endedID} {
+		viewer.handleFrame([]byte(`{"type":"watch","id":"w","story_id":"` + storyID.String() + `"}`))
+		if reply := readReply(t, viewer); reply.Type != "watching" {
+			t.Fatalf("expected watching, got %+v", reply)
+		}
+		readPresence(t, author)
+	}
+
+	// Losing access to the story drops the watch.
+	authorizer.mu.Lock()
+	authorizer.revoked = true
+	authorizer.mu.Unlock()
+	hub.recheck([]byte(`{"topic":"` + StoryTopic(revokedID) + `"}`))
+	if reply := readReply(t, viewer); reply.Type != "unwatched" || reply.StoryID != revokedID.String() || reply.Code != ErrForbidden {
+		t.Fatalf("expected forbidden unwatched, got %+v", reply)
+	}
+	if event := readPresence(t, author); event.StoryID != revokedID || event.Watching != 0 {
+		t.Fatalf("unexpected presence %+v", event)
+	}
+
+	// A story that ended is dropped rather than refreshed.
+	presence.mu.Lock()
+	presence.ended = map[uuid.UUID]bool{endedID: true}
+	presence.mu.Unlock()
+	hub.refreshWatchers()
+	if reply := readReply(t, viewer); reply.Type != "unwatched" || reply.StoryID != endedID.String() || reply.Code != ErrNotFound {
+		t.Fatalf("expected not_found unwatched, got %+v", reply)
+	}
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	if len(hub.watched) != 0 || len(viewer.watching) != 0 {
+		t.Fatal("watches were kept after access was lost")
+	}
+}
@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// inboxQueueSize bounds how many events may wait to be stored. When it
+	// is full, events are delivered live without a sequence number rather
+	// than blocking the request that sent them.
+	inboxQueueSize = 4096
+	// inboxBatchSize and inboxBatchWait bound how many events are stored in
+	// one statement and how long the first of them waits for company.
+	inboxBatchSize = 100
+	inboxBatchWait = 50 * time.Millisecond
+	// inboxWriteTimeout bounds one batch insert.
+	inboxWriteTimeout = 5 * time.Second
+)
+
+// InboxEntry is one event to store for a user. Append fills in Seq.
+type InboxEntry struct {
+	UserID  uuid.UUID
+	Type    string
+	Payload []byte
+	Seq     int64
+}
+
+// Inbox persists events per user and assigns each one a sequence number,
+// so sockets that were offline can replay what they missed.
+type Inbox interface {
+	// Append stores the entries in one write and sets each one's Seq. A
+	// user's entries are numbered in the order given.
+	Append(ctx context.Context, entries []InboxEntry) error
+	// MarkRead marks the user's events up to and including upToSeq as
+	// read and returns how many were unread.
+	MarkRead(ctx context.Context, userID uuid.UUID, upToSeq int64) (int64, error)
+}
+
+// pendingEvent is an event queued for the inbox, published once stored.
+type pendingEvent struct {
+	userID uuid.UUID
+	event  Event
+	body   []byte
+}
+
+// queueForInbox hands an event to persistEvents. It reports false when the
+// queue is full.
+func (h *Hub) queueForInbox(userID uuid.UUID, event Event) bool {
+	body, err := json.Marshal(event.Payload)
+	if err != nil {
+		return true
+	}
+	select {
+	case h.pending <- pendingEvent{userID: userID, event: event, body: body}:
+		return true
+	default:
+		return false
+	}
+}
+
+// persistEvents stores queued events in batches and publishes each one with
+// its sequence number. Events come off the queue in order, so a user's
+// events are numbered and published in the order they were sent.
+func (h *Hub) persistEvents() {
+	batch := make([]pendingEvent, 0, inboxBatchSize)
+	for first := range h.pending {
+		batch = append(batch[:0], first)
+		timer := time.NewTimer(inboxBatchWait)
+	collect:
+		for len(batch) < inboxBatchSize {
+			select {
+			case event := <-h.pending:
+				batch = append(batch, event)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		h.storeBatch(batch)
+	}
+}
+
+func (h *Hub) storeBatch(batch []pendingEvent) {
+	entries := make([]InboxEntry, len(batch))
+	for i, p := range batch {
+		entries[i] = InboxEntry{UserID: p.userID, Type: p.event.Type, Payload: p.body}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), inboxWriteTimeout)
+	err := h.inbox.Append(ctx, entries)
+	cancel()
+	if err != nil {
+		h.logger.Error("failed to store events in inbox, delivering without sequence numbers",
+			zap.Int("events", len(batch)), zap.Error(err))
+	}
+
+	for i, p := range batch {
+		if err == nil {
+			p.event.Seq = entries[i].Seq
+		}
+		h.publishToUser(p.userID, p.event)
+	}
+}
@@ -0,0 +1,300 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// presenceTTL is how long a watcher counts as present without a
+	// refresh, so sockets lost with their replica drop out on their own.
+	presenceTTL = 30 * time.Second
+	// presenceRefreshInterval is how often each hub renews its watchers.
+	presenceRefreshInterval = 10 * time.Second
+	// maxWatchesPerClient bounds how many stories one socket may watch.
+	maxWatchesPerClient = 10
+)
+
+// Watcher is one connection with a story open. A user watching from several
+// connections, on any replicas, is counted once.
+type Watcher struct {
+	UserID uuid.UUID
+	ConnID string
+}
+
+// Presence records which users have a story open, shared across replicas.
+type Presence interface {
+	// StoryAuthor returns the author of an active story; ok is false when
+	// the story is deleted, expired or does not exist.
+	StoryAuthor(ctx context.Context, storyID uuid.UUID) (authorID uuid.UUID, ok bool, err error)
+	// Watch marks the connections as watching the story for another ttl.
+	// It returns how many users are watching and how many connections had
+	// expired since the last call.
+	Watch(ctx context.Context, storyID uuid.UUID, watchers []Watcher, ttl time.Duration) (watching, expired int64, err error)
+	// Unwatch removes one connection from the story's watchers and returns
+	// how many users are left.
+	Unwatch(ctx context.Context, storyID uuid.UUID, watcher Watcher) (int64, error)
+	// ExpireWatchers drops expired connections from every story and
+	// returns how many users are left watching each story it dropped any
+	// from. Each expiry is returned to one caller only.
+	ExpireWatchers(ctx context.Context) (map[uuid.UUID]int64, error)
+}
+
+type StoryPresenceEvent struct {
+	StoryID  uuid.UUID `json:"story_id"`
+	Watching int64     `json:"watching"`
+}
+
+// watchedStory is a story watched from sockets on this process.
+type watchedStory struct {
+	authorID uuid.UUID
+	clients  map[*Client]bool
+}
+
+// addWatch records that client has storyID open. It fails when the client
+// is at its limit or no longer registered.
+func (h *Hub) addWatch(client *Client, storyID, authorID uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client.UserID][client]; !ok {
+		return false
+	}
+	if client.watching[storyID] {
+		return true
+	}
+	if len(client.watching) >= maxWatchesPerClient {
+		return false
+	}
+
+	client.watching[storyID] = true
+	story, ok := h.watched[storyID]
+	if !ok {
+		story = &watchedStory{authorID: authorID, clients: make(map[*Client]bool)}
+		h.watched[storyID] = story
+	}
+	story.clients[client] = true
+	return true
+}
+
+// removeWatch drops client's watch on storyID and reports whether it had
+// one, in which case the connection should leave the shared watcher set. The
+// caller holds h.mu.
+func (h *Hub) removeWatch(client *Client, storyID uuid.UUID) (authorID uuid.UUID, ok bool) {
+	if !client.watching[storyID] {
+		return uuid.Nil, false
+	}
+	delete(client.watching, storyID)
+
+	story, ok := h.watched[storyID]
+	if !ok {
+		return uuid.Nil, false
+	}
+	delete(story.clients, client)
+	if len(story.clients) == 0 {
+		delete(h.watched, storyID)
+	}
+	return story.authorID, true
+}
+
+// leaveStory removes a connection from a story's watchers and tells the
+// author how many users are left.
+func (h *Hub) leaveStory(ctx context.Context, storyID, authorID uuid.UUID, watcher Watcher) {
+	watching, err := h.presence.Unwatch(ctx, storyID, watcher)
+	if err != nil {
+		h.logger.Error("failed to remove story watcher",
+			zap.String("story_id", storyID.String()), zap.Error(err))
+		return
+	}
+	h.sendPresence(authorID, storyID, watching)
+}
+
+func (h *Hub) sendPresence(authorID, storyID uuid.UUID, watching int64) {
+	h.SendEphemeral(authorID, Event{
+		Type:    "story.presence",
+		Payload: StoryPresenceEvent{StoryID: storyID, Watching: watching},
+	})
+}
+
+// refreshPresence periodically renews this process's watchers and sweeps
+// expired watchers from every story.
+func (h *Hub) refreshPresence() {
+	ticker := time.NewTicker(presenceRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.refreshWatchers()
+		h.expireWatchers()
+	}
+}
+
+// refreshWatchers renews every watcher on this process before their
+// presence expires. Watches on stories that have expired or been deleted are
+// dropped instead, so they are not kept alive for as long as the socket is.
+func (h *Hub) refreshWatchers() {
+	type watchers struct {
+		clients  []*Client
+		watchers []Watcher
+	}
+
+	h.mu.RLock()
+	stories := make(map[uuid.UUID]watchers, len(h.watched))
+	for storyID, story := range h.watched {
+		var w watchers
+		for client := range story.clients {
+			w.clients = append(w.clients, client)
+			w.watchers = append(w.watchers, client.watcher())
+		}
+		stories[storyID] = w
+	}
+	h.mu.RUnlock()
+
+	for storyID, w := range stories {
+		ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+		h.refreshStory(ctx, storyID, w.clients, w.watchers)
+		cancel()
+	}
+}
+
+func (h *Hub) refreshStory(ctx context.Context, storyID uuid.UUID, clients []*Client, watchers []Watcher) {
+	authorID, active, err := h.presence.StoryAuthor(ctx, storyID)
+	if err != nil {
+		h.logger.Error("failed to check watched story",
+			zap.String("story_id", storyID.String()), zap.Error(err))
+		return
+	}
+	if !active {
+		for _, client := range clients {
+			h.dropWatch(ctx, client, storyID, ErrNotFound, "story is no longer available")
+		}
+		return
+	}
+
+	watching, expired, err := h.presence.Watch(ctx, storyID, watchers, presenceTTL)
+	if err != nil {
+		h.logger.Error("failed to refresh story watchers",
+			zap.String("story_id", storyID.String()), zap.Error(err))
+		return
+	}
+	if expired > 0 {
+		h.sendPresence(authorID, storyID, watching)
+	}
+}
+
+// dropWatch ends client's watch on storyID on the server's initiative and
+// tells the socket why with an "unwatched" frame.
+func (h *Hub) dropWatch(ctx context.Context, client *Client, storyID uuid.UUID, code, message string) {
+	h.mu.Lock()
+	authorID, ok := h.removeWatch(client, storyID)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	h.leaveStory(ctx, storyID, authorID, client.watcher())
+	client.reply(Frame{Type: "unwatched", StoryID: storyID.String(), Code: code, Message: message})
+}
+
+// expireWatchers reports stories whose watchers expired to their authors.
+func (h *Hub) expireWatchers() {
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	expired, err := h.presence.ExpireWatchers(ctx)
+	if err != nil {
+		h.logger.Error("failed to expire story watchers", zap.Error(err))
+		return
+	}
+	for storyID, watching := range expired {
+		authorID, ok, err := h.presence.StoryAuthor(ctx, storyID)
+		if err != nil {
+			h.logger.Error("failed to find story author for presence",
+				zap.String("story_id", storyID.String()), zap.Error(err))
+			continue
+		}
+		if ok {
+			h.sendPresence(authorID, storyID, watching)
+		}
+	}
+}
+
+// watch handles a "watch" frame: the client has a story open.
+func (c *Client) watch(ctx context.Context, frame Frame) {
+	storyID, err := uuid.Parse(frame.StoryID)
+	if err != nil {
+		c.replyError(frame, ErrInvalidFrame, "watch needs a story_id")
+		return
+	}
+	if c.hub.presence == nil || c.hub.authorizer == nil {
+		c.replyError(frame, ErrUnavailable, "presence is not available")
+		return
+	}
+
+	authorID, ok, err := c.watchableStory(ctx, storyID)
+	if err != nil {
+		c.hub.logger.Error("failed to check story for presence",
+			zap.String("story_id", storyID.String()), zap.Error(err))
+		c.replyError(frame, ErrInternal, "could not check access to story")
+		return
+	}
+	if !ok {
+		c.replyError(frame, ErrNotFound, "story not found")
+		return
+	}
+
+	// Authors looking at their own story are not an audience.
+	if authorID == c.UserID {
+		c.reply(Frame{Type: "watching", ID: frame.ID, StoryID: frame.StoryID})
+		return
+	}
+
+	if !c.hub.addWatch(c, storyID, authorID) {
+		c.replyError(frame, ErrTooManyWatches, "watch limit reached")
+		return
+	}
+
+	watching, _, err := c.hub.presence.Watch(ctx, storyID, []Watcher{c.watcher()}, presenceTTL)
+	if err != nil {
+		c.hub.mu.Lock()
+		c.hub.removeWatch(c, storyID)
+		c.hub.mu.Unlock()
+		c.hub.logger.Error("failed to add story watcher",
+			zap.String("story_id", storyID.String()), zap.Error(err))
+		c.replyError(frame, ErrInternal, "could not record presence")
+		return
+	}
+
+	c.hub.sendPresence(authorID, storyID, watching)
+	c.reply(Frame{Type: "watching", ID: frame.ID, StoryID: frame.StoryID})
+}
+
+// watchableStory returns the author of storyID if the story is active and
+// the client may see it.
+func (c *Client) watchableStory(ctx context.Context, storyID uuid.UUID) (uuid.UUID, bool, error) {
+	allowed, err := c.hub.authorizer.CanView(ctx, c.UserID, storyID)
+	if err != nil || !allowed {
+		return uuid.Nil, false, err
+	}
+	return c.hub.presence.StoryAuthor(ctx, storyID)
+}
+
+// unwatch handles an "unwatch" frame: the client closed a story.
+func (c *Client) unwatch(ctx context.Context, frame Frame) {
+	storyID, err := uuid.Parse(frame.StoryID)
+	if err != nil {
+		c.replyError(frame, ErrInvalidFrame, "unwatch needs a story_id")
+		return
+	}
+
+	c.hub.mu.Lock()
+	authorID, ok := c.hub.removeWatch(c, storyID)
+	c.hub.mu.Unlock()
+
+	if ok {
+		c.hub.leaveStory(ctx, storyID, authorID, c.watcher())
+	}
+	c.reply(Frame{Type: "unwatched", ID: frame.ID, StoryID: frame.StoryID})
+}
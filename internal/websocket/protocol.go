@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxTopicsPerClient bounds how many topics one socket may follow.
+const maxTopicsPerClient = 100
+
+// Frame is a message on the socket other than an event: a request from the
+// client or the server's reply to one. Replies echo the request's ID.
+type Frame struct {
+	Type     string `json:"type"`
+	ID       string `json:"id,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	StoryID  string `json:"story_id,omitempty"`
+	Seq      int64  `json:"seq,omitempty"`
+	UpToSeq  int64  `json:"up_to_seq,omitempty"`
+	TS       int64  `json:"ts,omitempty"`
+	ServerTS int64  `json:"server_ts,omitempty"`
+	Marked   *int64 `json:"marked,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Error codes sent in "error" frames.
+const (
+	ErrInvalidFrame   = "invalid_frame"
+	ErrUnknownType    = "unknown_type"
+	ErrInvalidTopic   = "invalid_topic"
+	ErrForbidden      = "forbidden"
+	ErrTooManyTopics  = "too_many_topics"
+	ErrTooManyWatches = "too_many_watches"
+	ErrNotFound       = "not_found"
+	ErrUnavailable    = "unavailable"
+	ErrInternal       = "internal_error"
+)
+
+// Authorizer decides which topics a user may follow: a story topic needs
+// access to the story, an author topic access to the author's stories.
+// Access is checked on subscribe and again whenever the hub is asked to
+// recheck the user or topic.
+type Authorizer interface {
+	CanView(ctx context.Context, viewerID, storyID uuid.UUID) (bool, error)
+	CanViewAuthor(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error)
+}
+
+func StoryTopic(storyID uuid.UUID) string {
+	return "story:" + storyID.String()
+}
+
+func AuthorTopic(authorID uuid.UUID) string {
+	return "author:" + authorID.String()
+}
+
+// parseTopic splits a topic into its kind ("story" or "author") and ID.
+func parseTopic(topic string) (string, uuid.UUID, bool) {
+	kind, raw, ok := strings.Cut(topic, ":")
+	if !ok || (kind != "story" && kind != "author") {
+		return "", uuid.Nil, false
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return "", uuid.Nil, false
+	}
+	return kind, id, true
+}
+
+// handleFrame answers one frame read from the socket.
+func (c *Client) handleFrame(data []byte) {
+	var frame Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		c.reply(Frame{Type: "error", Code: ErrInvalidFrame, Message: "frames must be JSON objects"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	switch frame.Type {
+	case "subscribe":
+		c.subscribe(ctx, frame)
+	case "unsubscribe":
+		if _, _, ok := parseTopic(frame.Topic); !ok {
+			c.replyError(frame, ErrInvalidTopic, "topic must be story:<id> or author:<id>")
+			return
+		}
+		c.hub.unsubscribeTopic(c, frame.Topic)
+		c.reply(Frame{Type: "unsubscribed", ID: frame.ID, Topic: frame.Topic})
+	case "watch":
+		c.watch(ctx, frame)
+	case "unwatch":
+		c.unwatch(ctx, frame)
+	case "ack":
+		c.ack(ctx, frame)
+	case "ping":
+		c.reply(Frame{Type: "pong", ID: frame.ID, TS: frame.TS, ServerTS: time.Now().UnixMilli()})
+	default:
+		c.replyError(frame, ErrUnknownType, "unknown frame type "+frame.Type)
+	}
+}
+
+func (c *Client) subscribe(ctx context.Context, frame Frame) {
+	if _, _, ok := parseTopic(frame.Topic); !ok {
+		c.replyError(frame, ErrInvalidTopic, "topic must be story:<id> or author:<id>")
+		return
+	}
+	if c.hub.authorizer == nil {
+		c.replyError(frame, ErrUnavailable, "topic subscriptions are not available")
+		return
+	}
+
+	allowed, err := c.hub.authorizeTopic(ctx, c.UserID, frame.Topic)
+	if err != nil {
+		c.hub.logger.Error("failed to authorize topic subscription",
+			zap.String("user_id", c.UserID.String()), zap.String("topic", frame.Topic), zap.Error(err))
+		c.replyError(frame, ErrInternal, "could not check access to topic")
+		return
+	}
+	if !allowed {
+		c.replyError(frame, ErrForbidden, "no access to topic")
+		return
+	}
+
+	if !c.hub.subscribeTopic(c, frame.Topic) {
+		c.replyError(frame, ErrTooManyTopics, "topic limit reached")
+		return
+	}
+	c.reply(Frame{Type: "subscribed", ID: frame.ID, Topic: frame.Topic})
+}
+
+// ack marks the user's notifications read up to the acknowledged sequence
+// number.
+func (c *Client) ack(ctx context.Context, frame Frame) {
+	if frame.Seq < 1 {
+		c.replyError(frame, ErrInvalidFrame, "ack needs a positive seq")
+		return
+	}
+	if c.hub.inbox == nil {
+		c.replyError(frame, ErrUnavailable, "notifications are not stored")
+		return
+	}
+
+	marked, err := c.hub.inbox.MarkRead(ctx, c.UserID, frame.Seq)
+	if err != nil {
+		c.hub.logger.Error("failed to mark notifications read",
+			zap.String("user_id", c.UserID.String()), zap.Error(err))
+		c.replyError(frame, ErrInternal, "could not mark notifications read")
+		return
+	}
+	c.reply(Frame{Type: "acked", ID: frame.ID, Seq: frame.Seq, Marked: &marked})
+}
+
+func (c *Client) replyError(request Frame, code, message string) {
+	c.reply(Frame{Type: "error", ID: request.ID, Code: code, Message: message})
+}
+
+// reply queues a frame for WritePump. Replies are dropped rather than
+// blocking the read loop when the client is not reading them.
+func (c *Client) reply(frame Frame) {
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		return
+	}
+	select {
+	case c.replies <- payload:
+	default:
+	}
+}
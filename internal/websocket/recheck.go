@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// recheckChannel carries requests to re-authorize topic subscriptions. Every
+// hub with an authorizer subscribes to it, since any of them may hold the
+// affected sockets.
+const recheckChannel = "recheck"
+
+type recheckRequest struct {
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	Topic  string     `json:"topic,omitempty"`
+}
+
+type subscription struct {
+	client *Client
+	topic  string
+}
+
+// RecheckUser re-authorizes the topic subscriptions of every socket userID
+// has open, on any replica, and drops those the user may no longer follow.
+// Call it after a change that can only take access away from the user, such
+// as a block or an unfollow.
+func (h *Hub) RecheckUser(userID uuid.UUID) {
+	h.publishRecheck(recheckRequest{UserID: &userID})
+}
+
+// RecheckTopic re-authorizes every subscriber of topic, on any replica. Call
+// it after a change to who may see an author or story, such as going private
+// or narrowing a story's visibility.
+func (h *Hub) RecheckTopic(topic string) {
+	h.publishRecheck(recheckRequest{Topic: topic})
+}
+
+func (h *Hub) publishRecheck(req recheckRequest) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	h.publish(&Message{Channel: recheckChannel, Payload: payload})
+}
+
+type watch struct {
+	client  *Client
+	storyID uuid.UUID
+}
+
+// recheck handles a request from recheckChannel. Subscriptions and watches
+// that are no longer allowed are dropped and the socket gets an
+// "unsubscribed" or "unwatched" frame with code "forbidden". When access
+// cannot be checked they are kept.
+func (h *Hub) recheck(payload []byte) {
+	var req recheckRequest
+	if err := json.Unmarshal(payload, &req); err != nil || h.authorizer == nil {
+		return
+	}
+
+	var subscriptions []subscription
+	var watches []watch
+	h.mu.RLock()
+	if req.UserID != nil {
+		for client := range h.clients[*req.UserID] {
+			for topic := range client.topics {
+				subscriptions = append(subscriptions, subscription{client: client, topic: topic})
+			}
+			for storyID := range client.watching {
+				watches = append(watches, watch{client: client, storyID: storyID})
+			}
+		}
+	}
+	if req.Topic != "" {
+		for client := range h.topics[req.Topic] {
+			subscriptions = append(subscriptions, subscription{client: client, topic: req.Topic})
+		}
+		// A story topic covers the story's watchers, an author topic the
+		// watchers of all the author's stories.
+		if kind, id, ok := parseTopic(req.Topic); ok {
+			for storyID, story := range h.watched {
+				if (kind == "story" && storyID == id) || (kind == "author" && story.authorID == id) {
+					for client := range story.clients {
+						watches = append(watches, watch{client: client, storyID: storyID})
+					}
+				}
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range subscriptions {
+		ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+		allowed, err := h.authorizeTopic(ctx, sub.client.UserID, sub.topic)
+		cancel()
+		if err != nil {
+			h.logger.Error("failed to recheck topic subscription",
+				zap.String("user_id", sub.client.UserID.String()), zap.String("topic", sub.topic), zap.Error(err))
+			continue
+		}
+		if allowed {
+			continue
+		}
+
+		h.unsubscribeTopic(sub.client, sub.topic)
+		sub.client.reply(Frame{Type: "unsubscribed", Topic: sub.topic, Code: ErrForbidden, Message: "access to topic was revoked"})
+	}
+
+	for _, w := range watches {
+		ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+		allowed, err := h.authorizer.CanView(ctx, w.client.UserID, w.storyID)
+		if err != nil {
+			h.logger.Error("failed to recheck story watch",
+				zap.String("user_id", w.client.UserID.String()), zap.String("story_id", w.storyID.String()), zap.Error(err))
+		} else if !allowed {
+			h.dropWatch(ctx, w.client, w.storyID, ErrForbidden, "access to story was revoked")
+		}
+		cancel()
+	}
+}
+
+// authorizeTopic reports whether userID may follow topic: a story topic needs
+// access to the story, an author topic access to the author's stories.
+func (h *Hub) authorizeTopic(ctx context.Context, userID uuid.UUID, topic string) (bool, error) {
+	kind, id, ok := parseTopic(topic)
+	if !ok {
+		return false, nil
+	}
+	if kind == "story" {
+		return h.authorizer.CanView(ctx, userID, id)
+	}
+	return h.authorizer.CanViewAuthor(ctx, userID, id)
+}
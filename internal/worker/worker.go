@@ -4,21 +4,42 @@ import (
 	"context"
 	"time"
 
+	"stories-service/internal/analytics"
+	"stories-service/internal/cache"
+	"stories-service/internal/config"
 	"stories-service/internal/db"
 	"stories-service/internal/metrics"
 
 	"go.uber.org/zap"
 )
 
+// rollupInterval is how often the analytics rollups are refreshed, and so
+// how far GET /me/stats can lag behind live activity.
+const rollupInterval = 5 * time.Minute
+
+// rollupSettle is how old a row must be before a refresh folds it in, so rows
+// from transactions that were still committing are not skipped.
+const rollupSettle = time.Minute
+
+// notificationRetention is how long events stay in users' inboxes. Sequence
+// numbers keep counting up after old events are purged.
+const notificationRetention = 30 * 24 * time.Hour
+
 type Worker struct {
-	db     *db.DB
-	logger *zap.Logger
+	db       *db.DB
+	cache    *cache.Cache
+	lifetime config.StoryLifetime
+	fanout   config.FeedFanout
+	logger   *zap.Logger
 }
 
-func NewWorker(database *db.DB, logger *zap.Logger) *Worker {
+func NewWorker(database *db.DB, cach *cache.Cache, lifetime config.StoryLifetime, fanout config.FeedFanout, logger *zap.Logger) *Worker {
 	return &Worker{
-		db:     database,
-		logger: logger,
+		db:       database,
+		cache:    cach,
+		lifetime: lifetime,
+		fanout:   fanout,
+		logger:   logger,
 	}
 }
 
@@ -26,6 +47,9 @@ func (w *Worker) Run(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
+	rollupTicker := time.NewTicker(rollupInterval)
+	defer rollupTicker.Stop()
+
 	w.logger.Info("worker started")
 
 	for {
@@ -35,6 +59,11 @@ func (w *Worker) Run(ctx context.Context) {
 			return
 		case <-ticker.C:
 			w.expireStories()
+			w.purgeRefreshTokens()
+			w.purgeNotifications()
+			w.trimTimelines(ctx)
+		case <-rollupTicker.C:
+			w.refreshRollups(ctx)
 		}
 	}
 }
@@ -66,3 +95,66 @@ func (w *Worker) expireStories() {
 
 	metrics.WorkerLatencySeconds.Observe(duration.Seconds())
 }
+
+func (w *Worker) purgeRefreshTokens() {
+	result, err := w.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < NOW()")
+	if err != nil {
+		w.logger.Error("failed to purge refresh tokens", zap.Error(err))
+		return
+	}
+
+	count, _ := result.RowsAffected()
+	if count > 0 {
+		w.logger.Info("expired refresh tokens purged", zap.Int64("count", count))
+	}
+}
+
+func (w *Worker) purgeNotifications() {
+	cutoff := time.Now().Add(-notificationRetention)
+	result, err := w.db.Exec("DELETE FROM notifications WHERE created_at < $1", cutoff)
+	if err != nil {
+		w.logger.Error("failed to purge notifications", zap.Error(err))
+		return
+	}
+
+	count, _ := result.RowsAffected()
+	if count > 0 {
+		w.logger.Info("old notifications purged", zap.Int64("count", count))
+	}
+}
+
+// trimTimelines drops timeline entries older than the longest allowed story
+// lifetime, which can no longer be active, and caps every timeline's length.
+func (w *Worker) trimTimelines(ctx context.Context) {
+	if w.cache == nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-w.lifetime.Max)
+	removed, err := w.cache.TrimTimelines(ctx, cutoff, int64(w.fanout.TimelineLength))
+	if err != nil {
+		w.logger.Error("failed to trim timelines", zap.Error(err))
+		return
+	}
+
+	if removed > 0 {
+		w.logger.Info("timelines trimmed", zap.Int64("removed", removed))
+	}
+}
+
+// refreshRollups folds the views, reactions and stories written since the
+// last refresh into the analytics rollups, rebuilding only the hours they
+// touched.
+func (w *Worker) refreshRollups(ctx context.Context) {
+	start := time.Now()
+
+	buckets, err := analytics.UpdateRollups(ctx, w.db, rollupSettle)
+	if err != nil {
+		w.logger.Error("failed to refresh rollups", zap.Error(err))
+		return
+	}
+
+	w.logger.Info("rollups refreshed",
+		zap.Int("buckets", buckets),
+		zap.Duration("duration", time.Since(start)))
+}